@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	sinkStdout = "stdout"
+	sinkFile = "file"
+	sinkWebhook = "webhook"
+	webhookTimeout = 10 * time.Second
+)
+
+type AlertConfiguration struct {
+	Rules []AlertRule `yaml:"rules"`
+	Sinks []AlertSink `yaml:"sinks"`
+}
+
+type AlertRule struct {
+	Name string `yaml:"name"`
+	Family string `yaml:"family"`
+	Venue string `yaml:"venue"`
+	Driver string `yaml:"driver"`
+	Session string `yaml:"session"`
+	Rank int `yaml:"rank"`
+	Above float64 `yaml:"above"`
+	Below float64 `yaml:"below"`
+	Move float64 `yaml:"move"`
+}
+
+type AlertSink struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+	URL string `yaml:"url"`
+}
+
+type alertMessage struct {
+	Rule string `json:"rule"`
+	Race string `json:"race"`
+	Venue string `json:"venue"`
+	Family string `json:"family"`
+	Market string `json:"market"`
+	Timestamp time.Time `json:"timestamp"`
+	Price float64 `json:"price"`
+	Reference float64 `json:"reference,omitempty"`
+	Rank int `json:"rank,omitempty"`
+	Text string `json:"text"`
+}
+
+type alertMarket struct {
+	path string
+	venue string
+	family string
+	name string
+	series []pricePoint
+	size int64
+	modTime time.Time
+	lastTimestamp time.Time
+}
+
+type alertTick struct {
+	market *alertMarket
+	point pricePoint
+}
+
+type alertEngine struct {
+	raceConfig RaceConfiguration
+	rules []AlertRule
+	sinks []alertSink
+	markets map[string]*alertMarket
+	fired map[string]bool
+}
+
+type alertSink interface {
+	send(message alertMessage)
+}
+
+type stdoutAlertSink struct {}
+
+type fileAlertSink struct {
+	path string
+}
+
+type webhookAlertSink struct {
+	url string
+	client *http.Client
+}
+
+func runAlerts(racePath string, interval time.Duration) {
+	loadConfiguration()
+	if len(configuration.Alerts.Rules) == 0 {
+		log.Fatal("No alert rules in configuration")
+	}
+	engine := alertEngine{
+		raceConfig: getFeedRace(racePath),
+		rules: configuration.Alerts.Rules,
+		sinks: getAlertSinks(configuration.Alerts.Sinks),
+		markets: map[string]*alertMarket{},
+		fired: map[string]bool{},
+	}
+	fmt.Printf("Watching markets of %s every %s with %d alert rules\n", engine.raceConfig.Path, interval, len(engine.rules))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ticks := engine.scan()
+		for _, tick := range ticks {
+			for _, rule := range engine.rules {
+				engine.evaluate(rule, tick)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func (a *AlertConfiguration) validate() {
+	for i := range a.Rules {
+		a.Rules[i].validate()
+	}
+	for _, sink := range a.Sinks {
+		sink.validate()
+	}
+}
+
+func (r *AlertRule) validate() {
+	if r.Name == "" {
+		log.Fatal("Name missing from alert rule")
+	}
+	if r.Family == "" {
+		r.Family = familyWin
+	}
+	_ = getMarketFamily(r.Family)
+	if r.Venue != "" {
+		_ = getVenue(r.Venue)
+	}
+	if r.Session != "" {
+		_ = getStrategyType(r.Session)
+	} else if r.Rank > 0 || r.Move > 0.0 {
+		log.Fatalf("Alert rule %s requires a session for its rank or move condition", r.Name)
+	}
+	if r.Above <= 0.0 && r.Below <= 0.0 && r.Move <= 0.0 {
+		log.Fatalf("Alert rule %s has no price condition", r.Name)
+	}
+}
+
+func (s *AlertSink) validate() {
+	switch s.Type {
+	case sinkStdout:
+	case sinkFile:
+		if s.Path == "" {
+			log.Fatal("Path missing from file alert sink")
+		}
+	case sinkWebhook:
+		if s.URL == "" {
+			log.Fatal("URL missing from webhook alert sink")
+		}
+	default:
+		log.Fatalf("Unknown alert sink type: %s", s.Type)
+	}
+}
+
+func getAlertSinks(sinkConfigs []AlertSink) []alertSink {
+	sinks := []alertSink{}
+	for _, sinkConfig := range sinkConfigs {
+		switch sinkConfig.Type {
+		case sinkStdout:
+			sinks = append(sinks, &stdoutAlertSink{})
+		case sinkFile:
+			sinks = append(sinks, &fileAlertSink{
+				path: sinkConfig.Path,
+			})
+		case sinkWebhook:
+			sinks = append(sinks, &webhookAlertSink{
+				url: sinkConfig.URL,
+				client: &http.Client{
+					Timeout: webhookTimeout,
+				},
+			})
+		}
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, &stdoutAlertSink{})
+	}
+	return sinks
+}
+
+func (e *alertEngine) scan() []alertTick {
+	ticks := []alertTick{}
+	for _, venue := range e.raceConfig.getVenues() {
+		directory := filepath.Join(configuration.Source, e.raceConfig.Path, venue.Directory)
+		if !commons.FileExists(directory) {
+			continue
+		}
+		for _, path := range getCSVPaths(directory, 0) {
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Fatalf("Failed to determine file size: %s", path)
+			}
+			market, exists := e.markets[path]
+			if exists && market.size == info.Size() && market.modTime.Equal(info.ModTime()) {
+				continue
+			}
+			if !exists {
+				family, name, matched := venue.matchMarket(filepath.Base(path))
+				if !matched {
+					continue
+				}
+				market = &alertMarket{
+					path: path,
+					venue: venue.Name,
+					family: family.name,
+					name: name,
+				}
+				e.markets[path] = market
+			}
+			market.series = readPriceSeries(path, venue)
+			market.size = info.Size()
+			market.modTime = info.ModTime()
+			for _, point := range market.series {
+				if point.timestamp.After(market.lastTimestamp) {
+					tick := alertTick{
+						market: market,
+						point: point,
+					}
+					ticks = append(ticks, tick)
+					market.lastTimestamp = point.timestamp
+				}
+			}
+		}
+	}
+	slices.SortStableFunc(ticks, func (a, b alertTick) int {
+		return a.point.timestamp.Compare(b.point.timestamp)
+	})
+	return ticks
+}
+
+func (e *alertEngine) evaluate(rule AlertRule, tick alertTick) {
+	market := tick.market
+	point := tick.point
+	if rule.Family != market.family || (rule.Venue != "" && rule.Venue != market.venue) {
+		return
+	}
+	if rule.Driver != "" && getSlug(rule.Driver) != market.name {
+		return
+	}
+	key := rule.Name + "/" + market.path
+	if e.fired[key] {
+		return
+	}
+	reference := 0.0
+	rank := 0
+	if rule.Session != "" {
+		sessionTime := e.raceConfig.getSessionTime(getStrategyType(rule.Session))
+		if !point.timestamp.After(sessionTime) {
+			return
+		}
+		var exists bool
+		reference, exists = getReferencePrice(market.series, sessionTime)
+		if !exists {
+			return
+		}
+		if rule.Rank > 0 {
+			rank = e.getRank(market, sessionTime)
+			if rank != rule.Rank {
+				return
+			}
+		}
+	}
+	if rule.Above > 0.0 && point.price <= rule.Above {
+		return
+	}
+	if rule.Below > 0.0 && point.price >= rule.Below {
+		return
+	}
+	if rule.Move > 0.0 && math.Abs(point.price - reference) < rule.Move {
+		return
+	}
+	e.fired[key] = true
+	text := fmt.Sprintf("%s: %s %s market of %s at %s traded at %.3f", rule.Name, market.venue, market.family, market.name, point.timestamp.Format(paperLogLayout), point.price)
+	if rule.Session != "" {
+		text += fmt.Sprintf(", %.3f at the start of %s", reference, rule.Session)
+	}
+	if rank > 0 {
+		text += fmt.Sprintf(", ranked #%d", rank)
+	}
+	message := alertMessage{
+		Rule: rule.Name,
+		Race: e.raceConfig.Path,
+		Venue: market.venue,
+		Family: market.family,
+		Market: market.name,
+		Timestamp: point.timestamp,
+		Price: point.price,
+		Reference: reference,
+		Rank: rank,
+		Text: text,
+	}
+	for _, sink := range e.sinks {
+		sink.send(message)
+	}
+}
+
+func (e *alertEngine) getRank(market *alertMarket, sessionTime time.Time) int {
+	type rankedMarket struct {
+		market *alertMarket
+		price float64
+	}
+	ranked := []rankedMarket{}
+	for _, other := range e.markets {
+		if other.venue != market.venue || other.family != market.family {
+			continue
+		}
+		price, exists := getReferencePrice(other.series, sessionTime)
+		if !exists {
+			continue
+		}
+		ranked = append(ranked, rankedMarket{
+			market: other,
+			price: price,
+		})
+	}
+	slices.SortFunc(ranked, func (a, b rankedMarket) int {
+		return cmp.Or(cmp.Compare(b.price, a.price), cmp.Compare(a.market.name, b.market.name))
+	})
+	i := slices.IndexFunc(ranked, func (r rankedMarket) bool {
+		return r.market == market
+	})
+	return i + 1
+}
+
+func getReferencePrice(series []pricePoint, timestamp time.Time) (float64, bool) {
+	var reference *pricePoint
+	for i := range series {
+		if series[i].timestamp.After(timestamp) {
+			break
+		}
+		reference = &series[i]
+	}
+	if reference == nil {
+		return 0.0, false
+	}
+	return reference.price, true
+}
+
+func (s *stdoutAlertSink) send(message alertMessage) {
+	fmt.Println(message.Text)
+}
+
+func (s *fileAlertSink) send(message alertMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Fatalf("Failed to serialize alert: %v", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("Failed to open alert file %s: %v", s.path, err)
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, string(data))
+	if err != nil {
+		log.Fatalf("Failed to write alert to %s: %v", s.path, err)
+	}
+}
+
+func (s *webhookAlertSink) send(message alertMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Fatalf("Failed to serialize alert: %v", err)
+	}
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to send alert to webhook %s: %v", s.url, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		log.Printf("Webhook %s rejected alert with status code %d", s.url, response.StatusCode)
+	}
+}
\ No newline at end of file
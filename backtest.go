@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"log"
 	"slices"
+	"strings"
 
-	"github.com/encratite/commons"
 	"gonum.org/v1/gonum/stat"
 )
 
@@ -30,30 +30,66 @@ const (
 
 type raceData struct {
 	name string
+	metadata RaceMetadata
 	drivers []driverData
 }
 
 type driverData struct {
 	name string
+	family string
+	venue string
 	practicePrice float64
 	qualifyingPrice float64
 	racePrice float64
+	finalPrice float64
+	practiceQuote priceQuote
+	qualifyingQuote priceQuote
+	raceQuote priceQuote
+	practiceMissing bool
+	qualifyingMissing bool
+	raceMissing bool
 	winner bool
+	void bool
 }
 
 type strategyParameters struct {
+	family string
+	venue VenueConfiguration
 	stratType strategyType
 	bets []strategyBet
 }
 
+type backtestResult struct {
+	parameters strategyParameters
+	returns []float64
+	total float64
+	riskAdjusted float64
+	skipped int
+}
+
 type strategyBet struct {
 	position int
 	yes bool
 }
 
-func runBacktest() {
+func runBacktest(family string, venueName string, filterString string, groupField string, policy SnapshotConfiguration) {
 	loadConfiguration()
+	configuration.Snapshot.override(policy)
+	venue := getVenue(venueName)
+	filters := parseRaceFilters(filterString)
 	races := loadRaces()
+	marketFamily := getMarketFamily(family)
+	races = filterRaces(races, marketFamily.name, venue.Name)
+	races = filterRaceMetadata(races, filters)
+	for _, group := range groupRaces(races, groupField) {
+		group.printHeader(groupField)
+		for _, strategy := range getBacktestStrategies(marketFamily.name, venue) {
+			executeBacktest(strategy, group.races)
+		}
+	}
+}
+
+func getBacktestStrategies(family string, venue VenueConfiguration) []strategyParameters {
 	stratTypes := []strategyType{
 		strategyPractice,
 		// strategyQualifying,
@@ -109,33 +145,56 @@ func runBacktest() {
 			},
 		},
 	}
+	strategies := []strategyParameters{}
 	for _, stratType := range stratTypes {
 		for _, bets := range betConfigurations {
 			strategy := strategyParameters{
+				family: family,
+				venue: venue,
 				stratType: stratType,
 				bets: bets,
 			}
-			executeBacktest(strategy, races)
+			strategies = append(strategies, strategy)
 		}
 	}
+	return strategies
 }
 
 func executeBacktest(parameters strategyParameters, races []raceData) {
+	result := getBacktestResult(parameters, races)
+	typeString := getStrategyTypeString(parameters.stratType)
+	fmt.Printf("Backtest result for type \"%s\" (%s markets):\n", typeString, parameters.family)
+	fmt.Printf("\tVenue: %s\n", parameters.venue.describe())
+	fmt.Printf("\tSnapshot: %s\n", configuration.Snapshot.describe())
+	if result.skipped > 0 {
+		fmt.Printf("\tSkipped: %d races with missing snapshots\n", result.skipped)
+	}
+	for _, bet := range parameters.bets {
+		fmt.Printf("\tPosition %d: %t\n", bet.position, bet.yes)
+	}
+	fmt.Printf("\tReturns: %+.1f%% (%.2f RAR)\n\n", 100.0 * result.total, result.riskAdjusted)
+}
+
+func getBacktestResult(parameters strategyParameters, races []raceData) backtestResult {
 	cash := 1.0
 	returns := []float64{}
+	skipped := 0
 	for _, race := range races {
+		if race.isMissing(parameters.stratType) {
+			skipped++
+			continue
+		}
 		raceReturns := getRaceReturns(parameters, race)
 		cash += raceReturns
 		returns = append(returns, raceReturns)
 	}
-	percentage := 100.0 * (cash - 1.0)
-	typeString := getStrategyTypeString(parameters.stratType)
-	fmt.Printf("Backtest result for type \"%s\":\n", typeString)
-	for _, bet := range parameters.bets {
-		fmt.Printf("\tPosition %d: %t\n", bet.position, bet.yes)
+	return backtestResult{
+		parameters: parameters,
+		returns: returns,
+		total: cash - 1.0,
+		riskAdjusted: stat.Mean(returns, nil) / stat.StdDev(returns, nil),
+		skipped: skipped,
 	}
-	riskAdjusted := stat.Mean(returns, nil) / stat.StdDev(returns, nil)
-	fmt.Printf("\tReturns: %+.1f%% (%.2f RAR)\n\n", percentage, riskAdjusted)
 }
 
 func getRaceReturns(parameters strategyParameters, race raceData) float64 {
@@ -146,6 +205,8 @@ func getRaceReturns(parameters strategyParameters, race raceData) float64 {
 		return cmp.Compare(price2, price1)
 	})
 	returns := 0.0
+	venue := parameters.venue
+	execution := venue.getExecutionModel()
 	for _, bet := range parameters.bets {
 		i := bet.position - 1
 		if i < 0 || i >= len(drivers) {
@@ -153,10 +214,14 @@ func getRaceReturns(parameters strategyParameters, race raceData) float64 {
 		}
 		driver := drivers[i]
 		price := driver.getPrice(parameters.stratType)
+		quote := driver.getQuote(parameters.stratType)
 		if !bet.yes {
 			price = 1.0 - price
+			quote = quote.invert()
 		}
 		betSize := positionSize / float64(len(parameters.bets))
+		notional := betSize * backtestBankroll
+		fillPrice := execution.getFillPrice(price, quote, notional)
 		if verbose {
 			if bet.yes {
 				fmt.Printf("Betting on %s at %.2f\n", driver.name, price)
@@ -166,23 +231,25 @@ func getRaceReturns(parameters strategyParameters, race raceData) float64 {
 		}
 		won := bet.yes == driver.winner
 		if won {
-			returns += betSize * (1.0 / (price + spread) - 1.0)
+			returns += venue.getWinReturns(betSize, fillPrice)
 		} else {
 			if enableStopLoss {
-				returns -= betSize * (stopLoss - spread)
+				stopPrice := fillPrice * (1.0 - stopLoss)
+				exitSpread := execution.getFillPrice(stopPrice, priceQuote{}, notional) - stopPrice
+				returns += venue.getLossReturns(betSize * (stopLoss - exitSpread))
 			} else {
-				returns -= betSize
+				returns += venue.getLossReturns(betSize)
 			}
 		}
 	}
-	winner, exists := commons.Find(drivers, func (d driverData) bool {
-		return d.winner
-	})
-	if !exists {
-		log.Fatalf("Unable to find winner for race: %s", race.name)
-	}
 	if verbose {
-		fmt.Printf("Returns: %.2f (%s, won by %s)\n", returns, race.name, winner.name)
+		winners := []string{}
+		for _, driver := range drivers {
+			if driver.winner {
+				winners = append(winners, driver.name)
+			}
+		}
+		fmt.Printf("Returns: %.2f (%s, won by %s)\n", returns, race.name, strings.Join(winners, ", "))
 	}
 	return returns
 }
@@ -201,6 +268,50 @@ func (d *driverData) getPrice(stratType strategyType) float64 {
 	return 0.0
 }
 
+func (d *driverData) getQuote(stratType strategyType) priceQuote {
+	switch stratType {
+	case strategyPractice:
+		return d.practiceQuote
+	case strategyQualifying:
+		return d.qualifyingQuote
+	case strategyRace:
+		return d.raceQuote
+	default:
+		log.Fatalf("Invalid strategy type: %d", stratType)
+	}
+	return priceQuote{}
+}
+
+func (d *driverData) isMissing(stratType strategyType) bool {
+	switch stratType {
+	case strategyPractice:
+		return d.practiceMissing
+	case strategyQualifying:
+		return d.qualifyingMissing
+	case strategyRace:
+		return d.raceMissing
+	default:
+		log.Fatalf("Invalid strategy type: %d", stratType)
+	}
+	return false
+}
+
+func (r *raceData) isMissing(stratType strategyType) bool {
+	return slices.ContainsFunc(r.drivers, func (d driverData) bool {
+		return d.isMissing(stratType)
+	})
+}
+
+func getStrategyType(name string) strategyType {
+	for _, stratType := range []strategyType{strategyPractice, strategyQualifying, strategyRace} {
+		if getStrategyTypeString(stratType) == name {
+			return stratType
+		}
+	}
+	log.Fatalf("Invalid strategy type: %s", name)
+	return strategyPractice
+}
+
 func getStrategyTypeString(stratType strategyType) string {
 	switch stratType {
 	case strategyPractice:
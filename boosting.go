@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"math"
+	"slices"
+)
+
+const (
+	boostTrees = 100
+	boostDepth = 3
+	boostLearningRate = 0.1
+	boostMinLeafSize = 20
+	boostMinHessian = 1e-6
+)
+
+type boostedTreeModel struct {
+	Bias float64 `json:"bias"`
+	LearningRate float64 `json:"learningRate"`
+	Trees []*treeNode `json:"trees"`
+}
+
+type treeNode struct {
+	Feature int `json:"feature"`
+	Threshold float64 `json:"threshold"`
+	Value float64 `json:"value"`
+	Left *treeNode `json:"left,omitempty"`
+	Right *treeNode `json:"right,omitempty"`
+}
+
+type treeSplit struct {
+	feature int
+	threshold float64
+	gain float64
+}
+
+func newBoostedTreeModel(_ hyperparameters) *boostedTreeModel {
+	return &boostedTreeModel{
+		LearningRate: boostLearningRate,
+		Trees: []*treeNode{},
+	}
+}
+
+func (m *boostedTreeModel) fit(features [][]float64, labels []float64, _ []featureMetaData) {
+	if len(features) == 0 {
+		log.Fatal("Unable to train model without samples")
+	}
+	positives := 0.0
+	for _, label := range labels {
+		positives += label
+	}
+	prior := min(max(positives / float64(len(labels)), probabilityEpsilon), 1.0 - probabilityEpsilon)
+	m.Bias = math.Log(prior / (1.0 - prior))
+	m.Trees = []*treeNode{}
+	scores := make([]float64, len(features))
+	for i := range scores {
+		scores[i] = m.Bias
+	}
+	indexes := []int{}
+	for i := range features {
+		indexes = append(indexes, i)
+	}
+	gradients := make([]float64, len(features))
+	hessians := make([]float64, len(features))
+	for range boostTrees {
+		for i, score := range scores {
+			p := 1.0 / (1.0 + math.Exp(-score))
+			gradients[i] = labels[i] - p
+			hessians[i] = p * (1.0 - p)
+		}
+		tree := buildTree(features, gradients, hessians, indexes, 0)
+		m.Trees = append(m.Trees, tree)
+		for i, x := range features {
+			scores[i] += m.LearningRate * tree.evaluate(x)
+		}
+	}
+}
+
+func (m *boostedTreeModel) predict(features []float64, _ featureMetaData) float64 {
+	score := m.Bias
+	for _, tree := range m.Trees {
+		score += m.LearningRate * tree.evaluate(features)
+	}
+	return 1.0 / (1.0 + math.Exp(-score))
+}
+
+func (m *boostedTreeModel) marshal() json.RawMessage {
+	return marshalModel(m)
+}
+
+func (m *boostedTreeModel) unmarshal(data json.RawMessage) {
+	unmarshalModel(data, m)
+}
+
+func buildTree(features [][]float64, gradients []float64, hessians []float64, indexes []int, depth int) *treeNode {
+	gradientSum := 0.0
+	hessianSum := 0.0
+	for _, i := range indexes {
+		gradientSum += gradients[i]
+		hessianSum += hessians[i]
+	}
+	node := &treeNode{
+		Value: gradientSum / max(hessianSum, boostMinHessian),
+	}
+	if depth >= boostDepth || len(indexes) < 2 * boostMinLeafSize {
+		return node
+	}
+	split, exists := findBestSplit(features, gradients, hessians, indexes, gradientSum, hessianSum)
+	if !exists {
+		return node
+	}
+	left := []int{}
+	right := []int{}
+	for _, i := range indexes {
+		if features[i][split.feature] < split.threshold {
+			left = append(left, i)
+		} else {
+			right = append(right, i)
+		}
+	}
+	node.Feature = split.feature
+	node.Threshold = split.threshold
+	node.Left = buildTree(features, gradients, hessians, left, depth + 1)
+	node.Right = buildTree(features, gradients, hessians, right, depth + 1)
+	return node
+}
+
+func findBestSplit(
+	features [][]float64,
+	gradients []float64,
+	hessians []float64,
+	indexes []int,
+	gradientSum float64,
+	hessianSum float64,
+) (treeSplit, bool) {
+	best := treeSplit{}
+	exists := false
+	parentScore := gradientSum * gradientSum / max(hessianSum, boostMinHessian)
+	sorted := slices.Clone(indexes)
+	for feature := range features[indexes[0]] {
+		slices.SortFunc(sorted, func (a, b int) int {
+			return cmp.Compare(features[a][feature], features[b][feature])
+		})
+		leftGradient := 0.0
+		leftHessian := 0.0
+		for position, i := range sorted[:len(sorted) - 1] {
+			leftGradient += gradients[i]
+			leftHessian += hessians[i]
+			leftSize := position + 1
+			if leftSize < boostMinLeafSize || len(sorted) - leftSize < boostMinLeafSize {
+				continue
+			}
+			value := features[i][feature]
+			nextValue := features[sorted[position + 1]][feature]
+			if value == nextValue {
+				continue
+			}
+			rightGradient := gradientSum - leftGradient
+			rightHessian := hessianSum - leftHessian
+			leftScore := leftGradient * leftGradient / max(leftHessian, boostMinHessian)
+			rightScore := rightGradient * rightGradient / max(rightHessian, boostMinHessian)
+			gain := leftScore + rightScore - parentScore
+			if gain > best.gain {
+				best = treeSplit{
+					feature: feature,
+					threshold: (value + nextValue) / 2.0,
+					gain: gain,
+				}
+				exists = true
+			}
+		}
+	}
+	return best, exists
+}
+
+func (n *treeNode) evaluate(features []float64) float64 {
+	if n.Left == nil || n.Right == nil {
+		return n.Value
+	}
+	if features[n.Feature] < n.Threshold {
+		return n.Left.evaluate(features)
+	}
+	return n.Right.evaluate(features)
+}
\ No newline at end of file
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+	bolt "go.etcd.io/bbolt"
+)
+
+type storedFingerprint struct {
+	Size int64 `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash string `json:"hash"`
+}
+
+type marketUpdate struct {
+	key string
+	market storedMarket
+	series *storedSeries
+	dirty bool
+}
+
+type classificationUpdate struct {
+	classification raceClassification
+	stored storedClassification
+	dirty bool
+}
+
+func getFingerprint(path string, previous *storedFingerprint) (storedFingerprint, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Fatalf("Failed to determine file size: %s", path)
+	}
+	if previous != nil && previous.Size == info.Size() && previous.ModTime.Equal(info.ModTime()) {
+		return *previous, false
+	}
+	hash := sha256.Sum256(commons.ReadFile(path))
+	fingerprint := storedFingerprint{
+		Size: info.Size(),
+		ModTime: info.ModTime(),
+		Hash: hex.EncodeToString(hash[:]),
+	}
+	changed := previous == nil || previous.Hash != fingerprint.Hash
+	return fingerprint, changed
+}
+
+func getObjectHash(object any) string {
+	data, err := json.Marshal(object)
+	if err != nil {
+		log.Fatalf("Failed to serialize object for hashing: %v", err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func (r *RaceConfiguration) getSessionHash() string {
+	sessions := []any{
+		r.Practice.Time,
+		r.Qualifying.Time,
+		r.Race.Time,
+		r.Results,
+		configuration.Snapshot,
+	}
+	return getObjectHash(sessions)
+}
+
+func (v *VenueConfiguration) getParserHash() string {
+	parser := []any{
+		marketCacheVersion,
+		v.Format,
+		v.TimestampLayout,
+		v.TimeZone,
+		v.TimestampColumn,
+		v.PriceColumn,
+		v.Family,
+		v.NamePattern,
+	}
+	return getObjectHash(parser)
+}
+
+func loadMarkets(db *bolt.DB, paths []string, raceConfig RaceConfiguration, venue VenueConfiguration) []driverData {
+	sessions := raceConfig.getSessionHash()
+	parser := venue.getParserHash()
+	previous := map[string]storedMarket{}
+	err := viewStore(db, func (tx *bolt.Tx) error {
+		for _, path := range paths {
+			key := getSeriesKey(raceConfig.Path, venue.Name, filepath.Base(path))
+			var market storedMarket
+			if getRecord(tx, bucketMarkets, key, &market) {
+				previous[key] = market
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read cached markets of %s: %v", raceConfig.Path, err)
+	}
+	updates := commons.ParallelMap(paths, func (path string) marketUpdate {
+		fileName := filepath.Base(path)
+		key := getSeriesKey(raceConfig.Path, venue.Name, fileName)
+		cached, exists := previous[key]
+		var previousFingerprint *storedFingerprint
+		if exists && cached.Parser == parser {
+			previousFingerprint = &cached.File
+		}
+		fingerprint, changed := getFingerprint(path, previousFingerprint)
+		update := marketUpdate{
+			key: key,
+		}
+		if previousFingerprint != nil && !changed && cached.Sessions == sessions {
+			update.market = cached
+			update.dirty = fingerprint != cached.File
+			update.market.File = fingerprint
+			return update
+		}
+		var series []pricePoint
+		if previousFingerprint != nil && !changed {
+			series = loadSeries(db, key)
+		}
+		if series == nil {
+			series = readPriceSeries(path, venue)
+			storedSeries := newStoredSeries(raceConfig.Path, venue.Name, fileName, series)
+			update.series = &storedSeries
+		}
+		driver := getDriverData(fileName, series, raceConfig, venue)
+		update.market = storedMarket{
+			Path: path,
+			File: fingerprint,
+			Race: raceConfig.Path,
+			Venue: venue.Name,
+			Parser: parser,
+			Sessions: sessions,
+			Driver: newStoredDriverData(driver),
+		}
+		update.dirty = true
+		return update
+	})
+	keys := []string{}
+	drivers := []driverData{}
+	dirtyCount := 0
+	parsedCount := 0
+	for _, update := range updates {
+		keys = append(keys, update.key)
+		drivers = append(drivers, update.market.Driver.getDriverData())
+		if update.dirty {
+			dirtyCount++
+		}
+		if update.series != nil {
+			parsedCount++
+		}
+	}
+	prefix := getSeriesKey(raceConfig.Path, venue.Name, "")
+	err = updateStore(db, func (tx *bolt.Tx) error {
+		for _, update := range updates {
+			if !update.dirty {
+				continue
+			}
+			err := putRecord(tx, bucketMarkets, update.key, update.market)
+			if err != nil {
+				return err
+			}
+			if update.series != nil {
+				err = putRecord(tx, bucketSeries, update.key, *update.series)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, bucket := range []string{bucketMarkets, bucketSeries} {
+			err := deleteStaleRecords(tx, bucket, prefix, keys)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to update cached markets of %s: %v", raceConfig.Path, err)
+	}
+	if parsedCount > 0 {
+		fmt.Printf("Parsed %d of %d %s markets of %s\n", parsedCount, len(paths), venue.Name, raceConfig.Path)
+	} else if dirtyCount > 0 {
+		fmt.Printf("Updated %d of %d cached %s markets of %s\n", dirtyCount, len(paths), venue.Name, raceConfig.Path)
+	}
+	return drivers
+}
+
+func loadSeries(db *bolt.DB, key string) []pricePoint {
+	var series storedSeries
+	exists := false
+	err := viewStore(db, func (tx *bolt.Tx) error {
+		exists = getRecord(tx, bucketSeries, key, &series)
+		return nil
+	})
+	if err != nil || !exists {
+		return nil
+	}
+	return series.getPricePoints()
+}
+
+func deleteStaleRecords(tx *bolt.Tx, bucket string, prefix string, keys []string) error {
+	bucketHandle := tx.Bucket([]byte(bucket))
+	if bucketHandle == nil {
+		return nil
+	}
+	stale := [][]byte{}
+	cursor := bucketHandle.Cursor()
+	for key, _ := cursor.Seek([]byte(prefix)); key != nil && strings.HasPrefix(string(key), prefix); key, _ = cursor.Next() {
+		if !slices.Contains(keys, string(key)) {
+			stale = append(stale, slices.Clone(key))
+		}
+	}
+	for _, key := range stale {
+		err := bucketHandle.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func storeRace(db *bolt.DB, raceConfig RaceConfiguration, venues []string) {
+	race := storedRace{
+		Path: raceConfig.Path,
+		Practice: raceConfig.Practice.Time,
+		Qualifying: raceConfig.Qualifying.Time,
+		Race: raceConfig.Race.Time,
+		Metadata: raceConfig.RaceMetadata,
+		Venues: venues,
+	}
+	err := updateStore(db, func (tx *bolt.Tx) error {
+		var previous storedRace
+		exists := getRecord(tx, bucketRaces, race.Path, &previous)
+		if exists && getObjectHash(previous) == getObjectHash(race) {
+			return nil
+		}
+		return putRecord(tx, bucketRaces, race.Path, race)
+	})
+	if err != nil {
+		log.Fatalf("Failed to store race %s: %v", raceConfig.Path, err)
+	}
+}
+
+func loadResults() ([]driverSeasonalData, []wikiEvent, []raceClassification) {
+	paths := downloadFiles()
+	db := openCache()
+	defer closeStore(db)
+	drivers := []driverSeasonalData{}
+	events := []wikiEvent{}
+	for _, path := range paths {
+		seasonDrivers, seasonEvents := loadSeason(db, path)
+		drivers = mergeDrivers(drivers, seasonDrivers)
+		events = append(events, seasonEvents...)
+	}
+	events = downloadEventFiles(events)
+	classifications := loadEventClassifications(db, events)
+	return drivers, events, classifications
+}
+
+func loadSeason(db *bolt.DB, dataPath wikiDataPath) ([]driverSeasonalData, []wikiEvent) {
+	key := fmt.Sprintf("%d", dataPath.season)
+	var cached storedSeason
+	exists := false
+	err := viewStore(db, func (tx *bolt.Tx) error {
+		exists = getRecord(tx, bucketSeasons, key, &cached)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read cached season %d: %v", dataPath.season, err)
+	}
+	var previousFingerprint *storedFingerprint
+	if exists && cached.Path == dataPath.path {
+		previousFingerprint = &cached.File
+	}
+	fingerprint, changed := getFingerprint(dataPath.path, previousFingerprint)
+	if previousFingerprint != nil && !changed {
+		if fingerprint != cached.File {
+			cached.File = fingerprint
+			putSeason(db, key, cached)
+		}
+		return cached.getResults()
+	}
+	drivers, events := parseFile(dataPath)
+	stored := newStoredSeason(dataPath.season, dataPath.path, fingerprint, drivers, events)
+	putSeason(db, key, stored)
+	return drivers, events
+}
+
+func putSeason(db *bolt.DB, key string, season storedSeason) {
+	err := updateStore(db, func (tx *bolt.Tx) error {
+		return putRecord(tx, bucketSeasons, key, season)
+	})
+	if err != nil {
+		log.Fatalf("Failed to store season %d: %v", season.Season, err)
+	}
+}
+
+func loadEventClassifications(db *bolt.DB, events []wikiEvent) []raceClassification {
+	previous := map[string]storedClassification{}
+	err := viewStore(db, func (tx *bolt.Tx) error {
+		for _, event := range events {
+			key := getClassificationKey(event.season, event.id)
+			var classification storedClassification
+			if getRecord(tx, bucketClassifications, key, &classification) {
+				previous[key] = classification
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read cached classifications: %v", err)
+	}
+	updates := commons.ParallelMap(events, func (event wikiEvent) classificationUpdate {
+		cached, exists := previous[getClassificationKey(event.season, event.id)]
+		var previousFingerprint *storedFingerprint
+		if exists && cached.Path == event.path {
+			previousFingerprint = &cached.File
+		}
+		fingerprint, changed := getFingerprint(event.path, previousFingerprint)
+		if previousFingerprint != nil && !changed {
+			dirty := fingerprint != cached.File
+			cached.File = fingerprint
+			return classificationUpdate{
+				classification: cached.getClassification(),
+				stored: cached,
+				dirty: dirty,
+			}
+		}
+		classification := parseClassification(event)
+		return classificationUpdate{
+			classification: classification,
+			stored: newStoredClassification(classification, event.path, fingerprint),
+			dirty: true,
+		}
+	})
+	classifications := []raceClassification{}
+	for _, update := range updates {
+		classifications = append(classifications, update.classification)
+	}
+	err = updateStore(db, func (tx *bolt.Tx) error {
+		for _, update := range updates {
+			if !update.dirty {
+				continue
+			}
+			key := getClassificationKey(update.stored.Season, update.stored.ID)
+			err := putRecord(tx, bucketClassifications, key, update.stored)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to store classifications: %v", err)
+	}
+	return classifications
+}
\ No newline at end of file
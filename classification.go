@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/encratite/commons"
+	"golang.org/x/net/html"
+)
+
+const (
+	wikiBaseURL = "https://en.wikipedia.org"
+	frontRow = 2
+)
+
+type wikiEvent struct {
+	season int
+	id int
+	code string
+	url string
+	path string
+}
+
+type raceClassification struct {
+	season int
+	id int
+	entries []classificationEntry
+}
+
+type classificationEntry struct {
+	driver string
+	constructor string
+	qualifying int
+	grid int
+	result raceResult
+	position int
+}
+
+func downloadEventFiles(events []wikiEvent) []wikiEvent {
+	output := []wikiEvent{}
+	for _, event := range events {
+		directory := filepath.Join(dataDirectory, fmt.Sprintf("%d", event.season))
+		commons.CreateDirectory(directory)
+		fileName := fmt.Sprintf("%02d.html", event.id)
+		event.path = filepath.Join(directory, fileName)
+		exists := commons.FileExists(event.path)
+		if !exists {
+			url := wikiBaseURL + event.url
+			err := commons.DownloadFile(url, event.path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Downloaded %s (%d %s)\n", event.path, event.season, event.code)
+		}
+		output = append(output, event)
+	}
+	return output
+}
+
+func parseClassification(event wikiEvent) raceClassification {
+	htmlData := commons.ReadFile(event.path)
+	reader := strings.NewReader(string(htmlData))
+	doc, err := htmlquery.Parse(reader)
+	if err != nil {
+		log.Fatalf("Failed to parse HTML: %v", err)
+	}
+	classification := raceClassification{
+		season: event.season,
+		id: event.id,
+		entries: []classificationEntry{},
+	}
+	qualifyingTables := htmlquery.Find(doc, "//table[.//th[normalize-space(.) = 'Q1'] and .//th[normalize-space(.) = 'Q3']]")
+	raceTables := htmlquery.Find(doc, "//table[.//th[normalize-space(.) = 'Laps'] and .//th[normalize-space(.) = 'Grid']]")
+	if len(raceTables) == 0 {
+		log.Fatalf("Failed to locate race classification in %s", event.path)
+	}
+	raceTable := raceTables[len(raceTables) - 1]
+	parseClassificationTable(raceTable, "Grid", event.path, func (entry *classificationEntry, positionText string, grid int) {
+		entry.grid = grid
+		entry.result, entry.position = parseResultText(positionText)
+		classification.entries = append(classification.entries, *entry)
+	})
+	if len(qualifyingTables) > 0 {
+		qualifyingTable := qualifyingTables[len(qualifyingTables) - 1]
+		parseClassificationTable(qualifyingTable, "Final grid", event.path, func (entry *classificationEntry, positionText string, _ int) {
+			i := slices.IndexFunc(classification.entries, func (e classificationEntry) bool {
+				return e.driver == entry.driver
+			})
+			if i == -1 {
+				return
+			}
+			position, err := commons.ParseInt(positionText)
+			if err == nil {
+				classification.entries[i].qualifying = position
+			}
+		})
+	}
+	return classification
+}
+
+func parseClassificationTable(
+	table *html.Node,
+	gridHeader string,
+	path string,
+	callback func (*classificationEntry, string, int),
+) {
+	rows := htmlquery.Find(table, "./tbody/tr")
+	if len(rows) < 2 {
+		log.Fatalf("Failed to extract rows from classification table in %s", path)
+	}
+	headerCells := htmlquery.Find(rows[0], "./th")
+	driverIndex := getColumnIndex(headerCells, "Driver")
+	constructorIndex := getColumnIndex(headerCells, "Constructor")
+	gridIndex := getColumnIndex(headerCells, gridHeader)
+	if driverIndex == -1 || constructorIndex == -1 || gridIndex == -1 {
+		log.Fatalf("Failed to determine columns of classification table in %s", path)
+	}
+	for _, row := range rows[1:] {
+		cells := htmlquery.Find(row, "./*[self::th or self::td]")
+		if len(cells) <= gridIndex || htmlquery.ExistsAttr(cells[0], "colspan") {
+			continue
+		}
+		positionText := getCellText(cells[0])
+		driver := getCellText(cells[driverIndex])
+		constructor := getCellText(cells[constructorIndex])
+		if driver == "" || constructor == "" {
+			continue
+		}
+		grid, err := commons.ParseInt(getCellText(cells[gridIndex]))
+		if err != nil {
+			grid = 0
+		}
+		entry := classificationEntry{
+			driver: driver,
+			constructor: constructor,
+		}
+		callback(&entry, positionText, grid)
+	}
+}
+
+func getColumnIndex(headerCells []*html.Node, name string) int {
+	index := 0
+	for _, cell := range headerCells {
+		if getCellText(cell) == name {
+			return index
+		}
+		span, err := commons.ParseInt(htmlquery.SelectAttr(cell, "colspan"))
+		if err != nil || span < 1 {
+			span = 1
+		}
+		index += span
+	}
+	return -1
+}
+
+func getCellText(cell *html.Node) string {
+	text := htmlquery.InnerText(cell)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	text = commons.Trim(text)
+	return text
+}
+
+func parseResultText(resultText string) (raceResult, int) {
+	resultText = strings.Replace(resultText, "†", "", 1)
+	position, err := commons.ParseInt(resultText)
+	if err == nil {
+		return resultPosition, position
+	}
+	switch resultText {
+	case "Ret":
+		return resultRetired, 0
+	case "DSQ":
+		return resultDisqualified, 0
+	default:
+		return resultOther, 0
+	}
+}
+
+func applyClassifications(drivers []driverSeasonalData, classifications []raceClassification) {
+	for i := range drivers {
+		driver := &drivers[i]
+		for j := range driver.races {
+			race := &driver.races[j]
+			classification, exists := commons.Find(classifications, func (c raceClassification) bool {
+				return c.season == race.season && c.id == race.id
+			})
+			if !exists {
+				continue
+			}
+			entry, exists := commons.Find(classification.entries, func (e classificationEntry) bool {
+				return e.driver == driver.name
+			})
+			if !exists {
+				continue
+			}
+			race.constructor = entry.constructor
+			race.grid = entry.grid
+			race.qualifying = entry.qualifying
+			teammate, exists := commons.Find(classification.entries, func (e classificationEntry) bool {
+				return e.constructor == entry.constructor && e.driver != entry.driver
+			})
+			if exists {
+				race.teammate = teammate.driver
+				race.teammateResult = teammate.result
+				race.teammatePosition = teammate.position
+			}
+		}
+	}
+}
\ No newline at end of file
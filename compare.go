@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type venuePrices struct {
+	name string
+	prices map[string]driverData
+}
+
+func compareVenues(family string, snapshot string) {
+	loadConfiguration()
+	stratType := getStrategyType(snapshot)
+	marketFamily := getMarketFamily(family)
+	races := loadRaces()
+	notional := positionSize * backtestBankroll
+	for _, race := range races {
+		venues := []string{}
+		markets := []venuePrices{}
+		for _, driver := range race.drivers {
+			if driver.family != marketFamily.name || driver.void {
+				continue
+			}
+			if !slices.Contains(venues, driver.venue) {
+				venues = append(venues, driver.venue)
+			}
+			i := slices.IndexFunc(markets, func (m venuePrices) bool {
+				return m.name == driver.name
+			})
+			if i == -1 {
+				market := venuePrices{
+					name: driver.name,
+					prices: map[string]driverData{},
+				}
+				markets = append(markets, market)
+				i = len(markets) - 1
+			}
+			markets[i].prices[driver.venue] = driver
+		}
+		if len(venues) < 2 {
+			continue
+		}
+		slices.SortFunc(markets, func (a, b venuePrices) int {
+			return cmp.Compare(b.getBestPrice(stratType), a.getBestPrice(stratType))
+		})
+		fmt.Printf("%s (%s markets, %s prices):\n", race.name, marketFamily.name, snapshot)
+		header := fmt.Sprintf("\t%-30s", "Driver")
+		for _, venue := range venues {
+			header += fmt.Sprintf(" %12s", venue)
+		}
+		fmt.Printf("%s %8s\n", header, "Spread")
+		fieldCost := 0.0
+		fieldComplete := true
+		for _, market := range markets {
+			line := fmt.Sprintf("\t%-30s", market.name)
+			minPrice := 1.0
+			maxPrice := 0.0
+			bestYes := 1.0
+			bestYesVenue := ""
+			bestNo := 1.0
+			bestNoVenue := ""
+			for _, venueName := range venues {
+				driver, exists := market.prices[venueName]
+				if !exists {
+					line += fmt.Sprintf(" %12s", "-")
+					continue
+				}
+				price := driver.getPrice(stratType)
+				quote := driver.getQuote(stratType)
+				line += fmt.Sprintf(" %12.3f", price)
+				minPrice = min(minPrice, price)
+				maxPrice = max(maxPrice, price)
+				venue := getVenue(venueName)
+				execution := venue.getExecutionModel()
+				yesPrice := execution.getFillPrice(price, quote, notional)
+				noPrice := execution.getFillPrice(1.0 - price, quote.invert(), notional)
+				if yesPrice < bestYes {
+					bestYes = yesPrice
+					bestYesVenue = venueName
+				}
+				if noPrice < bestNo {
+					bestNo = noPrice
+					bestNoVenue = venueName
+				}
+			}
+			fmt.Printf("%s %8.3f\n", line, maxPrice - minPrice)
+			if bestYesVenue != "" && bestNoVenue != "" && bestYesVenue != bestNoVenue && bestYes + bestNo < 1.0 {
+				format := "\t\tArbitrage: yes at %s for %.3f, no at %s for %.3f, margin %.3f\n"
+				fmt.Printf(format, bestYesVenue, bestYes, bestNoVenue, bestNo, 1.0 - bestYes - bestNo)
+			}
+			if bestYesVenue == "" {
+				fieldComplete = false
+			}
+			fieldCost += bestYes
+		}
+		if marketFamily.winners > 0 && fieldComplete {
+			payout := float64(marketFamily.winners)
+			if fieldCost < payout {
+				fmt.Printf("\tField arbitrage: buying every market at the best venue costs %.3f for a payout of %.0f\n", fieldCost, payout)
+			} else {
+				fmt.Printf("\tBest venue field overround: %.1f%%\n", 100.0 * (fieldCost / payout - 1.0))
+			}
+		}
+		fmt.Printf("\tVenues: %s\n\n", strings.Join(venues, ", "))
+	}
+}
+
+func (p *venuePrices) getBestPrice(stratType strategyType) float64 {
+	best := 0.0
+	for _, driver := range p.prices {
+		best = max(best, driver.getPrice(stratType))
+	}
+	return best
+}
\ No newline at end of file
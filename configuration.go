@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"slices"
 	"time"
 
 	"github.com/encratite/commons"
@@ -9,27 +10,39 @@ import (
 )
 
 const (
-	configurationPath = "configuration/configuration.yaml"
+	defaultConfigurationPath = "configuration/configuration.yaml"
 	timeLayout = "2006-01-02 15:04"
+	timeZoneLayout = "2006-01-02 15:04 -07:00"
 )
 
 type Configuration struct {
 	Source string `yaml:"source"`
+	Settlements string `yaml:"settlements"`
+	Venues []VenueConfiguration `yaml:"venues"`
 	Races []RaceConfiguration `yaml:"races"`
+	Alerts AlertConfiguration `yaml:"alerts"`
+	Snapshot SnapshotConfiguration `yaml:"snapshot"`
 }
 
 type RaceConfiguration struct {
 	Path string `yaml:"path"`
+	RaceMetadata `yaml:",inline"`
 	Practice *SerializableTime `yaml:"practice"`
 	Qualifying *SerializableTime `yaml:"qualifying"`
 	Race *SerializableTime `yaml:"race"`
+	TimeZone string `yaml:"timeZone"`
+	Results *RaceResults `yaml:"results"`
+	Venues []string `yaml:"venues"`
+	location *time.Location
 }
 
 type SerializableTime struct {
 	time.Time
+	explicitOffset bool
 }
 
 var configuration *Configuration
+var configurationPath = defaultConfigurationPath
 
 func loadConfiguration() {
 	if configuration != nil {
@@ -41,6 +54,16 @@ func loadConfiguration() {
 	if err != nil {
 		log.Fatal("Failed to unmarshal YAML:", err)
 	}
+	if configuration.Settlements != "" {
+		settlements := loadSettlements(configuration.Settlements)
+		for i := range configuration.Races {
+			race := &configuration.Races[i]
+			results, exists := settlements[race.Path]
+			if exists && race.Results == nil {
+				race.Results = &results
+			}
+		}
+	}
 	configuration.validate()
 }
 
@@ -48,9 +71,42 @@ func (c *Configuration) validate() {
 	if c.Source == "" {
 		log.Fatalf("Source missing from configuration file")
 	}
-	for _, race := range c.Races {
-		race.validate()
+	for i := range c.Venues {
+		c.Venues[i].validate()
+	}
+	c.Snapshot.validate()
+	for i := range c.Races {
+		c.Races[i].validate()
+	}
+	c.Alerts.validate()
+}
+
+func getRaceConfiguration(path string) RaceConfiguration {
+	return configuration.Races[getRaceIndex(path)]
+}
+
+func getRaceIndex(path string) int {
+	i := slices.IndexFunc(configuration.Races, func (r RaceConfiguration) bool {
+		return r.Path == path
+	})
+	if i == -1 {
+		log.Fatalf("Unable to find race in configuration: %s", path)
+	}
+	return i
+}
+
+func (r *RaceConfiguration) getSessionTime(session strategyType) time.Time {
+	switch session {
+	case strategyPractice:
+		return r.Practice.Time
+	case strategyQualifying:
+		return r.Qualifying.Time
+	case strategyRace:
+		return r.Race.Time
+	default:
+		log.Fatalf("Invalid session: %d", session)
 	}
+	return time.Time{}
 }
 
 func (r *RaceConfiguration) validate() {
@@ -62,11 +118,20 @@ func (r *RaceConfiguration) validate() {
 		r.Qualifying,
 		r.Race,
 	}
+	for _, venue := range r.Venues {
+		i := slices.IndexFunc(configuration.Venues, func (v VenueConfiguration) bool {
+			return v.Name == venue
+		})
+		if i == -1 {
+			log.Fatalf("Unknown venue %s in race configuration: %s", venue, r.Path)
+		}
+	}
 	for _, t := range times {
 		if t == nil {
 			log.Fatalf("Missing timestamp in race configuration: %s", r.Path)
 		}
 	}
+	r.validateTimeZone()
 	if !r.Practice.Before(r.Qualifying.Time) || !r.Qualifying.Before(r.Race.Time) {
 		log.Fatalf("Invalid times in race configuration: %s", r.Path)
 	}
@@ -79,10 +144,17 @@ func (r *RaceConfiguration) validate() {
 	if delta > limit {
 		log.Fatalf("Erroneous qualifying or race time with a delta of %.1f hours: %s", delta.Hours(), r.Path)
 	}
+	r.validateMetadata()
 }
 
 func (d *SerializableTime) UnmarshalYAML(value *yaml.Node) error {
-	timestamp, err := time.Parse(timeLayout, value.Value)
+	timestamp, err := time.Parse(timeZoneLayout, value.Value)
+	if err == nil {
+		d.Time = timestamp
+		d.explicitOffset = true
+		return nil
+	}
+	timestamp, err = time.Parse(timeLayout, value.Value)
 	if err != nil {
 		log.Fatalf("Failed to parse timestamp: %s", value.Value)
 	}
@@ -6,23 +6,53 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
 
-	"github.com/encratite/commons"
+	bolt "go.etcd.io/bbolt"
 )
 
 func loadRaces() []raceData {
+	db := openCache()
+	defer closeStore(db)
 	races := []raceData{}
 	for _, raceConfig := range configuration.Races {
-		race := loadRace(raceConfig)
+		race := loadRace(db, raceConfig)
 		races = append(races, race)
 	}
 	return races
 }
 
-func loadRace(raceConfig RaceConfiguration) raceData {
-	directory := filepath.Join(configuration.Source, raceConfig.Path)
+func loadRace(db *bolt.DB, raceConfig RaceConfiguration) raceData {
+	drivers := []driverData{}
+	venues := []string{}
+	for _, venue := range raceConfig.getVenues() {
+		venueDrivers := loadVenue(db, raceConfig, venue)
+		drivers = append(drivers, venueDrivers...)
+		venues = append(venues, venue.Name)
+	}
+	storeRace(db, raceConfig, venues)
+	data := raceData{
+		name: raceConfig.Path,
+		metadata: raceConfig.RaceMetadata,
+		drivers: drivers,
+	}
+	return data
+}
+
+func loadVenue(db *bolt.DB, raceConfig RaceConfiguration, venue VenueConfiguration) []driverData {
+	paths := getMarketPaths(raceConfig, venue)
+	drivers := loadMarkets(db, paths, raceConfig, venue)
+	validateWinners(drivers, raceConfig, venue)
+	return drivers
+}
+
+func getMarketPaths(raceConfig RaceConfiguration, venue VenueConfiguration) []string {
+	directory := filepath.Join(configuration.Source, raceConfig.Path, venue.Directory)
+	return getCSVPaths(directory, driverMinFileSize)
+}
+
+func getCSVPaths(directory string, minFileSize int64) []string {
 	entries, err := os.ReadDir(directory)
 	if err != nil {
 		log.Fatalf("Unable to read directory: %s", directory)
@@ -36,79 +66,133 @@ func loadRace(raceConfig RaceConfiguration) raceData {
 			if err != nil {
 				log.Fatalf("Failed to determine file size: %s", path)
 			}
-			if info.Size() < driverMinFileSize {
+			if info.Size() < minFileSize {
 				continue
 			}
 			paths = append(paths, path)
 		}
 	}
-	drivers := commons.ParallelMap(paths, func (path string) driverData {
-		driver := loadDriver(path, raceConfig)
-		return driver
-	})
-	winnerCount := 0
-	for _, driver := range drivers {
-		if driver.winner {
-			winnerCount++
+	return paths
+}
+
+func validateWinners(drivers []driverData, raceConfig RaceConfiguration, venue VenueConfiguration) {
+	for _, family := range marketFamilies {
+		if family.winners == 0 {
+			continue
+		}
+		marketCount := 0
+		winnerCount := 0
+		for _, driver := range drivers {
+			if driver.family != family.name {
+				continue
+			}
+			marketCount++
+			if driver.winner {
+				winnerCount++
+			}
+		}
+		if marketCount == 0 || winnerCount == family.winners {
+			continue
+		}
+		format := "Invalid number of winners in %s markets of venue %s for race %s (%d)"
+		if family.winners > 1 {
+			log.Printf(format, family.name, venue.Name, raceConfig.Path, winnerCount)
+		} else {
+			log.Fatalf(format, family.name, venue.Name, raceConfig.Path, winnerCount)
 		}
 	}
-	if winnerCount != 1 {
-		log.Fatalf("Invalid number of winners for race %s (%d)", raceConfig.Path, winnerCount)
+}
+
+func getDriverData(fileName string, series []pricePoint, raceConfig RaceConfiguration, venue VenueConfiguration) driverData {
+	family, name, exists := venue.matchMarket(fileName)
+	if !exists {
+		log.Fatalf("Unable to determine market family and name of driver: %s", fileName)
 	}
-	data := raceData{
-		name: raceConfig.Path,
-		drivers: drivers,
+	policy := configuration.Snapshot
+	practice, practiceExists := policy.extract(series, raceConfig.Practice.Time)
+	qualifying, qualifyingExists := policy.extract(series, raceConfig.Qualifying.Time)
+	race, raceExists := policy.extract(series, raceConfig.Race.Time)
+	if !practiceExists || !qualifyingExists || !raceExists {
+		log.Fatalf("Failed to extract prices of %s in %s", fileName, raceConfig.Path)
+	}
+	finalPrice := series[len(series) - 1].price
+	winner, void := settleMarket(family, name, finalPrice, raceConfig)
+	data := driverData{
+		name: name,
+		family: family.name,
+		venue: venue.Name,
+		practicePrice: practice.price,
+		qualifyingPrice: qualifying.price,
+		racePrice: race.price,
+		finalPrice: finalPrice,
+		practiceQuote: practice.quote,
+		qualifyingQuote: qualifying.quote,
+		raceQuote: race.quote,
+		practiceMissing: practice.missing,
+		qualifyingMissing: qualifying.missing,
+		raceMissing: race.missing,
+		winner: winner,
+		void: void,
 	}
 	return data
 }
 
-func loadDriver(path string, raceConfig RaceConfiguration) driverData {
-	fileName := filepath.Base(path)
-	pattern := regexp.MustCompile("will-(.+?)-win-")
-	matches := pattern.FindStringSubmatch(fileName)
-	if matches == nil {
-		log.Fatalf("Unable to extract name of driver: %s", fileName)
-	}
-	name := matches[1]
+func readPriceSeries(path string, venue VenueConfiguration) []pricePoint {
 	file, err := os.Open(path)
 	if err != nil {
 		log.Fatalf("Failed to read driver data: %v", err)
 	}
 	defer file.Close()
 	reader := csv.NewReader(file)
-	_, _ = reader.Read()
-	var previousPrice *float64
-	var practicePrice *float64
-	var qualifyingPrice *float64
-	var racePrice *float64
+	reader.FieldsPerRecord = -1
+	header, _ := reader.Read()
+	timestampIndex := venue.TimestampColumn
+	priceIndex := venue.PriceColumn
+	if priceIndex == 0 {
+		priceIndex = 1
+	}
+	bidIndex := getColumn(header, "bid")
+	askIndex := getColumn(header, "ask")
+	series := []pricePoint{}
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		timestamp := commons.MustParseTime(record[0])
-		price := commons.MustParseFloat(record[1])
-		if previousPrice != nil {
-			if practicePrice == nil && timestamp.After(raceConfig.Practice.Time) {
-				practicePrice = previousPrice
-			} else if qualifyingPrice == nil && timestamp.After(raceConfig.Qualifying.Time) {
-				qualifyingPrice = previousPrice
-			} else if racePrice == nil && timestamp.After(raceConfig.Race.Time) {
-				racePrice = previousPrice
-			}
+		if err != nil {
+			log.Fatalf("Failed to read record from %s: %v", path, err)
+		}
+		if timestampIndex >= len(record) || priceIndex >= len(record) || record[priceIndex] == "" {
+			continue
 		}
-		previousPrice = &price
+		point := pricePoint{
+			timestamp: venue.parseTimestamp(record[timestampIndex]),
+			price: venue.parsePrice(record[priceIndex]),
+			quote: getQuote(record, bidIndex, askIndex, venue),
+		}
+		series = append(series, point)
 	}
-	if practicePrice == nil || qualifyingPrice == nil || racePrice == nil || previousPrice == nil {
-		log.Fatalf("Failed to extract prices from %s", path)
+	return series
+}
+
+func getColumn(header []string, name string) int {
+	return slices.IndexFunc(header, func (column string) bool {
+		return strings.EqualFold(strings.TrimSpace(column), name)
+	})
+}
+
+func getQuote(record []string, bidIndex int, askIndex int, venue VenueConfiguration) priceQuote {
+	if bidIndex == -1 || askIndex == -1 || bidIndex >= len(record) || askIndex >= len(record) {
+		return priceQuote{}
 	}
-	winner := *previousPrice > winnerPriceLimit
-	data := driverData{
-		name: name,
-		practicePrice: *practicePrice,
-		qualifyingPrice: *qualifyingPrice,
-		racePrice: *racePrice,
-		winner: winner,
+	if record[bidIndex] == "" || record[askIndex] == "" {
+		return priceQuote{}
+	}
+	bid := venue.parsePrice(record[bidIndex])
+	ask := venue.parsePrice(record[askIndex])
+	return priceQuote{
+		bid: min(bid, ask),
+		ask: max(bid, ask),
+		exists: true,
 	}
-	return data
 }
\ No newline at end of file
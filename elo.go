@@ -0,0 +1,133 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"slices"
+)
+
+const (
+	eloInitialRating = 1500.0
+	eloFactor = 32.0
+	eloScale = 400.0
+)
+
+type eloModel struct {
+	Ratings map[string]float64 `json:"ratings"`
+	Field []string `json:"field"`
+}
+
+func newEloModel() *eloModel {
+	return &eloModel{
+		Ratings: map[string]float64{},
+		Field: []string{},
+	}
+}
+
+func (m *eloModel) fit(_ [][]float64, labels []float64, metaData []featureMetaData) {
+	m.Ratings = map[string]float64{}
+	m.Field = []string{}
+	races := []raceKey{}
+	for _, currentMetaData := range metaData {
+		key := raceKey{
+			season: currentMetaData.season,
+			id: currentMetaData.id,
+		}
+		if !slices.Contains(races, key) {
+			races = append(races, key)
+		}
+	}
+	slices.SortFunc(races, func (a, b raceKey) int {
+		if a.season != b.season {
+			return cmp.Compare(a.season, b.season)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	for _, race := range races {
+		field := []string{}
+		winners := map[string]bool{}
+		for i, currentMetaData := range metaData {
+			if currentMetaData.season != race.season || currentMetaData.id != race.id {
+				continue
+			}
+			for _, driver := range []string{currentMetaData.driver1, currentMetaData.driver2} {
+				if !slices.Contains(field, driver) {
+					field = append(field, driver)
+					winners[driver] = true
+				}
+				if labels[i] != 1.0 {
+					winners[driver] = false
+				}
+			}
+		}
+		m.Field = field
+		winner := ""
+		winnerCount := 0
+		for driver, isWinner := range winners {
+			if isWinner {
+				winner = driver
+				winnerCount++
+			}
+		}
+		if len(field) < 3 || winnerCount != 1 {
+			continue
+		}
+		m.update(winner, field)
+	}
+}
+
+func (m *eloModel) update(winner string, field []string) {
+	for _, driver := range field {
+		if driver == winner {
+			continue
+		}
+		expected := getEloExpectation(m.getRating(winner), m.getRating(driver))
+		delta := eloFactor * (1.0 - expected)
+		m.Ratings[winner] = m.getRating(winner) + delta
+		m.Ratings[driver] = m.getRating(driver) - delta
+	}
+}
+
+func (m *eloModel) predict(_ []float64, metaData featureMetaData) float64 {
+	field := slices.Clone(m.Field)
+	for _, driver := range []string{metaData.driver1, metaData.driver2} {
+		if !slices.Contains(field, driver) {
+			field = append(field, driver)
+		}
+	}
+	total := 0.0
+	for _, driver := range field {
+		total += m.getStrength(driver)
+	}
+	pairStrength := m.getStrength(metaData.driver1) + m.getStrength(metaData.driver2)
+	return pairStrength / total
+}
+
+func (m *eloModel) marshal() json.RawMessage {
+	return marshalModel(m)
+}
+
+func (m *eloModel) unmarshal(data json.RawMessage) {
+	unmarshalModel(data, m)
+}
+
+func (m *eloModel) getRating(driver string) float64 {
+	rating, exists := m.Ratings[driver]
+	if !exists {
+		return eloInitialRating
+	}
+	return rating
+}
+
+func (m *eloModel) getStrength(driver string) float64 {
+	return getEloStrength(m.getRating(driver))
+}
+
+func getEloStrength(rating float64) float64 {
+	return math.Pow(10.0, rating / eloScale)
+}
+
+func getEloExpectation(rating1 float64, rating2 float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10.0, (rating2 - rating1) / eloScale))
+}
\ No newline at end of file
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+const (
+	executionFlat = "flat"
+	executionProportional = "proportional"
+	executionBidAsk = "bidask"
+	executionDepth = "depth"
+	backtestBankroll = 1000.0
+)
+
+type priceQuote struct {
+	bid float64
+	ask float64
+	exists bool
+}
+
+type executionModel interface {
+	getFillPrice(price float64, quote priceQuote, notional float64) float64
+	describe() string
+}
+
+type flatSpreadExecution struct {
+	spread float64
+}
+
+type proportionalSpreadExecution struct {
+	relativeSpread float64
+}
+
+type bidAskExecution struct {
+	fallback flatSpreadExecution
+}
+
+type depthExecution struct {
+	spread float64
+	depth float64
+	slippage float64
+}
+
+type cappedExecution struct {
+	model executionModel
+	maxFillPrice float64
+}
+
+func (v *VenueConfiguration) getExecutionModel() executionModel {
+	model := v.getUncappedExecutionModel()
+	if v.MaxFillPrice > 0.0 {
+		return &cappedExecution{
+			model: model,
+			maxFillPrice: v.MaxFillPrice,
+		}
+	}
+	return model
+}
+
+func (v *VenueConfiguration) getUncappedExecutionModel() executionModel {
+	switch v.Execution {
+	case "", executionFlat:
+		return &flatSpreadExecution{
+			spread: v.Spread,
+		}
+	case executionProportional:
+		return &proportionalSpreadExecution{
+			relativeSpread: v.RelativeSpread,
+		}
+	case executionBidAsk:
+		return &bidAskExecution{
+			fallback: flatSpreadExecution{
+				spread: v.Spread,
+			},
+		}
+	case executionDepth:
+		return &depthExecution{
+			spread: v.Spread,
+			depth: v.Depth,
+			slippage: v.DepthSlippage,
+		}
+	default:
+		log.Fatalf("Unknown execution model in venue configuration %s: %s", v.Name, v.Execution)
+	}
+	return nil
+}
+
+func (v *VenueConfiguration) getWinReturns(betSize float64, fillPrice float64) float64 {
+	winnings := betSize * (1.0 / fillPrice - 1.0)
+	return winnings * (1.0 - v.WinningsFee) - v.getTradeFee()
+}
+
+func (v *VenueConfiguration) getLossReturns(loss float64) float64 {
+	return -loss - v.getTradeFee()
+}
+
+func (v *VenueConfiguration) getTradeFee() float64 {
+	return v.TradeFee / backtestBankroll
+}
+
+func (v *VenueConfiguration) describe() string {
+	model := v.getExecutionModel()
+	fees := []string{}
+	if v.WinningsFee > 0.0 {
+		fees = append(fees, fmt.Sprintf("%.1f%% of winnings", 100.0 * v.WinningsFee))
+	}
+	if v.TradeFee > 0.0 {
+		fees = append(fees, fmt.Sprintf("%.2f per trade", v.TradeFee))
+	}
+	if len(fees) == 0 {
+		fees = append(fees, "no fees")
+	}
+	return fmt.Sprintf("%s, %s, %s", v.Name, model.describe(), strings.Join(fees, ", "))
+}
+
+func (e *flatSpreadExecution) getFillPrice(price float64, _ priceQuote, _ float64) float64 {
+	return price + e.spread
+}
+
+func (e *flatSpreadExecution) describe() string {
+	return fmt.Sprintf("flat spread of %.3f", e.spread)
+}
+
+func (e *proportionalSpreadExecution) getFillPrice(price float64, _ priceQuote, _ float64) float64 {
+	return price * (1.0 + e.relativeSpread)
+}
+
+func (e *proportionalSpreadExecution) describe() string {
+	return fmt.Sprintf("proportional spread of %.1f%%", 100.0 * e.relativeSpread)
+}
+
+func (e *bidAskExecution) getFillPrice(price float64, quote priceQuote, notional float64) float64 {
+	if !quote.exists || quote.ask <= 0.0 {
+		return e.fallback.getFillPrice(price, quote, notional)
+	}
+	return quote.ask
+}
+
+func (e *bidAskExecution) describe() string {
+	return fmt.Sprintf("historical ask, falling back to %s", e.fallback.describe())
+}
+
+func (e *depthExecution) getFillPrice(price float64, _ priceQuote, notional float64) float64 {
+	slippage := e.slippage * math.Sqrt(notional / e.depth)
+	return price + e.spread / 2.0 + slippage
+}
+
+func (e *depthExecution) describe() string {
+	return fmt.Sprintf("half spread of %.3f plus %.3f slippage at %.0f depth", e.spread / 2.0, e.slippage, e.depth)
+}
+
+func (e *cappedExecution) getFillPrice(price float64, quote priceQuote, notional float64) float64 {
+	return min(e.model.getFillPrice(price, quote, notional), e.maxFillPrice)
+}
+
+func (e *cappedExecution) describe() string {
+	return fmt.Sprintf("%s, capped at %.2f", e.model.describe(), e.maxFillPrice)
+}
+
+func (q priceQuote) invert() priceQuote {
+	if !q.exists {
+		return q
+	}
+	return priceQuote{
+		bid: 1.0 - q.ask,
+		ask: 1.0 - q.bid,
+		exists: true,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strings"
+)
+
+const (
+	decayFactor = 0.8
+)
+
+type featureExtractor func (driver1 driverSeasonalData, driver2 driverSeasonalData, k int, windowSize int) []float64
+
+type featureSet struct {
+	name string
+	featureNames []string
+	extract featureExtractor
+	usesRaceGrid bool
+}
+
+var featureSets = []featureSet{
+	{
+		name: "simple",
+		featureNames: []string{"wins", "second place", "won last race"},
+		extract: getSimpleFeatures,
+	},
+	{
+		name: "combo",
+		featureNames: []string{"1-2 finishes", "1-x finishes", "2-x finishes", "retirements", "won last race"},
+		extract: getComboFeatures,
+	},
+	{
+		name: "grid",
+		featureNames: []string{"best grid position", "worst grid position", "front row lockout", "team podiums", "teammate head-to-head"},
+		extract: getGridFeatures,
+		usesRaceGrid: true,
+	},
+	{
+		name: "decay",
+		featureNames: []string{"decayed wins", "decayed podiums", "decayed finishing score"},
+		extract: getDecayFeatures,
+	},
+	{
+		name: "dnf",
+		featureNames: []string{"wins", "driver 1 DNF rate", "driver 2 DNF rate"},
+		extract: getDNFFeatures,
+	},
+	{
+		name: "pole",
+		featureNames: []string{"wins", "driver 1 pole rate", "driver 2 pole rate"},
+		extract: getPoleFeatures,
+	},
+	{
+		name: "elo",
+		featureNames: []string{"wins", "driver 1 rating", "driver 2 rating", "constructor 1 rating", "constructor 2 rating"},
+		extract: getRatingFeatures,
+	},
+}
+
+func getFeatureSets(featureString string) []featureSet {
+	sets := []featureSet{}
+	for _, name := range strings.Split(featureString, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		set := getFeatureSet(name)
+		sets = append(sets, set)
+	}
+	if len(sets) == 0 {
+		log.Fatalf("No feature sets specified")
+	}
+	return sets
+}
+
+func getFeatureSet(name string) featureSet {
+	for _, set := range featureSets {
+		if set.name == name {
+			return set
+		}
+	}
+	names := []string{}
+	for _, set := range featureSets {
+		names = append(names, set.name)
+	}
+	log.Fatalf("Unknown feature set \"%s\", available sets: %s", name, strings.Join(names, ", "))
+	return featureSet{}
+}
+
+func getRaceFeatures(
+	set featureSet,
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	return set.extract(driver1, driver2, k, windowSize)
+}
+
+func getSimpleFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	firstRace := driver1.races[k - 1]
+	lastRace := driver1.races[k - windowSize]
+	if !enableMultiSeason && firstRace.season != lastRace.season {
+		return nil
+	}
+	wins := 0
+	secondPlace := 0
+	wonLastRace := 0.0
+	for l := 1; l <= windowSize; l++ {
+		windowRace1 := driver1.races[k - l]
+		windowRace2, exists := getMatchingRace(driver2, windowRace1)
+		if !exists {
+			return nil
+		}
+		if windowRace1.isWin() || windowRace2.isWin() {
+			if l == 1 {
+				wonLastRace = 1.0
+			} else {
+				wins++
+			}
+		}
+		if windowRace1.isPosition(2) || windowRace2.isPosition(2) {
+			secondPlace++
+		}
+	}
+	features := []float64{
+		float64(wins),
+		float64(secondPlace),
+		wonLastRace,
+	}
+	return features
+}
+
+func getComboFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	position12 := 0
+	position10 := 0
+	position20 := 0
+	retired := 0
+	wonLastRace := 0.0
+	firstRace := driver1.races[k - 1]
+	lastRace := driver1.races[k - windowSize]
+	if !enableMultiSeason && firstRace.season != lastRace.season {
+		return nil
+	}
+	for l := 1; l <= windowSize; l++ {
+		windowRace1 := driver1.races[k - l]
+		windowRace2, exists := getMatchingRace(driver2, windowRace1)
+		if !exists {
+			return nil
+		}
+		r1p1 := windowRace1.isPosition(1)
+		r1p2 := windowRace1.isPosition(2)
+		r1p0 := !r1p1 && !r1p2
+		r2p1 := windowRace2.isPosition(1)
+		r2p2 := windowRace2.isPosition(2)
+		r2p0 := !r2p1 && !r2p2
+		if (r1p1 && r2p2) || (r2p1 && r1p2) {
+			position12++
+		} else if (r1p1 && r2p0) || (r2p1 && r1p0) {
+			position10++
+		} else if (r1p2 && r2p0) || (r2p2 && r1p0) {
+			position20++
+		}
+		if windowRace1.result == resultRetired || windowRace2.result == resultRetired {
+			retired++
+		}
+		if windowRace1.isWin() || windowRace2.isWin() {
+			wonLastRace = 1.0
+		} else {
+			wonLastRace = 0.0
+		}
+	}
+	features := []float64{
+		float64(position12),
+		float64(position10),
+		float64(position20),
+		float64(retired),
+		float64(wonLastRace),
+	}
+	return features
+}
+
+func getGridFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	if k >= len(driver1.races) {
+		return nil
+	}
+	race1 := driver1.races[k]
+	race2, exists := getMatchingRace(driver2, race1)
+	if !exists || race1.grid == 0 || race2.grid == 0 {
+		return nil
+	}
+	firstRace := driver1.races[k - 1]
+	lastRace := driver1.races[k - windowSize]
+	if !enableMultiSeason && firstRace.season != lastRace.season {
+		return nil
+	}
+	frontRowLockout := 0.0
+	if race1.grid <= frontRow && race2.grid <= frontRow {
+		frontRowLockout = 1.0
+	}
+	teamPodiums := 0
+	teammateWins := 0
+	teammateRaces := 0
+	for l := 1; l <= windowSize; l++ {
+		windowRace1 := driver1.races[k - l]
+		windowRace2, exists := getMatchingRace(driver2, windowRace1)
+		if !exists {
+			return nil
+		}
+		for _, windowRace := range []driverRaceResult{windowRace1, windowRace2} {
+			if windowRace.isPodium() || windowRace.isTeammatePodium() {
+				teamPodiums++
+			}
+			if windowRace.teammate != "" {
+				if windowRace.beatTeammate() {
+					teammateWins++
+				}
+				teammateRaces++
+			}
+		}
+	}
+	teammateHeadToHead := 0.5
+	if teammateRaces > 0 {
+		teammateHeadToHead = float64(teammateWins) / float64(teammateRaces)
+	}
+	features := []float64{
+		float64(min(race1.grid, race2.grid)),
+		float64(max(race1.grid, race2.grid)),
+		frontRowLockout,
+		float64(teamPodiums),
+		teammateHeadToHead,
+	}
+	return features
+}
+
+
+func getDecayFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	window, exists := getWindowRaces(driver1, driver2, k, windowSize)
+	if !exists {
+		return nil
+	}
+	wins := 0.0
+	podiums := 0.0
+	score := 0.0
+	for l, races := range window {
+		weight := math.Pow(decayFactor, float64(l))
+		for _, race := range races {
+			if race.isWin() {
+				wins += weight
+			}
+			if race.isPodium() {
+				podiums += weight
+			}
+			if race.result == resultPosition {
+				score += weight / float64(race.position)
+			}
+		}
+	}
+	features := []float64{
+		wins,
+		podiums,
+		score,
+	}
+	return features
+}
+
+func getDNFFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	window, exists := getWindowRaces(driver1, driver2, k, windowSize)
+	if !exists {
+		return nil
+	}
+	wins := 0
+	retired1 := 0
+	retired2 := 0
+	for _, races := range window {
+		if races[0].isWin() || races[1].isWin() {
+			wins++
+		}
+		if races[0].result == resultRetired {
+			retired1++
+		}
+		if races[1].result == resultRetired {
+			retired2++
+		}
+	}
+	features := []float64{
+		float64(wins),
+		float64(retired1) / float64(windowSize),
+		float64(retired2) / float64(windowSize),
+	}
+	return features
+}
+
+func getPoleFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	window, exists := getWindowRaces(driver1, driver2, k, windowSize)
+	if !exists {
+		return nil
+	}
+	wins := 0
+	poles1 := 0
+	poles2 := 0
+	for _, races := range window {
+		if races[0].isWin() || races[1].isWin() {
+			wins++
+		}
+		if races[0].pole {
+			poles1++
+		}
+		if races[1].pole {
+			poles2++
+		}
+	}
+	features := []float64{
+		float64(wins),
+		float64(poles1) / float64(windowSize),
+		float64(poles2) / float64(windowSize),
+	}
+	return features
+}
+
+func getRatingFeatures(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) []float64 {
+	window, exists := getWindowRaces(driver1, driver2, k, windowSize)
+	if !exists {
+		return nil
+	}
+	wins := 0
+	for _, races := range window {
+		if races[0].isWin() || races[1].isWin() {
+			wins++
+		}
+	}
+	lastRaces := window[0]
+	if lastRaces[0].rating == 0 || lastRaces[1].rating == 0 {
+		return nil
+	}
+	features := []float64{
+		float64(wins),
+		lastRaces[0].rating - eloInitialRating,
+		lastRaces[1].rating - eloInitialRating,
+		lastRaces[0].constructorRating - eloInitialRating,
+		lastRaces[1].constructorRating - eloInitialRating,
+	}
+	return features
+}
+
+func getWindowRaces(
+	driver1 driverSeasonalData,
+	driver2 driverSeasonalData,
+	k int,
+	windowSize int,
+) ([][2]driverRaceResult, bool) {
+	firstRace := driver1.races[k - 1]
+	lastRace := driver1.races[k - windowSize]
+	if !enableMultiSeason && firstRace.season != lastRace.season {
+		return nil, false
+	}
+	window := [][2]driverRaceResult{}
+	for l := 1; l <= windowSize; l++ {
+		windowRace1 := driver1.races[k - l]
+		windowRace2, exists := getMatchingRace(driver2, windowRace1)
+		if !exists {
+			return nil, false
+		}
+		window = append(window, [2]driverRaceResult{windowRace1, windowRace2})
+	}
+	return window, true
+}
\ No newline at end of file
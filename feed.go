@@ -0,0 +1,297 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	feedTimestampLayout = "2006-01-02 15:04:05"
+	feedHeader = "timestamp,price,bid,ask"
+	feedTimeout = 30 * time.Second
+	feedRaceDuration = 4 * time.Hour
+	mockPath = "/quotes"
+	mockLeadTime = time.Hour
+)
+
+type feedSnapshot struct {
+	Time time.Time `json:"time"`
+	Quotes []feedQuote `json:"quotes"`
+}
+
+type feedQuote struct {
+	Market string `json:"market"`
+	Timestamp time.Time `json:"timestamp"`
+	Price float64 `json:"price"`
+	Bid float64 `json:"bid,omitempty"`
+	Ask float64 `json:"ask,omitempty"`
+}
+
+type feedWriter struct {
+	directory string
+	venue VenueConfiguration
+	lastTimestamps map[string]time.Time
+}
+
+type mockServer struct {
+	series map[string][]pricePoint
+	start time.Time
+	started time.Time
+	speed float64
+}
+
+func runFeed(url string, racePath string, family string, venueName string, snapshot string, betsString string, interval time.Duration) {
+	loadConfiguration()
+	raceConfig := getFeedRace(racePath)
+	venue := getVenue(venueName)
+	if venue.Format != "" && venue.Format != formatProbability {
+		log.Fatalf("Live feeds can only be written to venues with probability prices: %s", venue.Name)
+	}
+	if venue.TimestampColumn != 0 || venue.PriceColumn > 1 {
+		log.Fatalf("Live feeds require the default column layout in venue %s", venue.Name)
+	}
+	marketFamily := getMarketFamily(family)
+	writer := feedWriter{
+		directory: filepath.Join(configuration.Source, raceConfig.Path, venue.Directory),
+		venue: venue,
+		lastTimestamps: map[string]time.Time{},
+	}
+	commons.CreateDirectory(writer.directory)
+	engine := newPaperEngine(venue, raceConfig)
+	writer.seed(engine, marketFamily.name)
+	strategy := &rankStrategy{
+		session: getStrategyType(snapshot),
+		bets: parseBets(betsString),
+	}
+	client := &http.Client{
+		Timeout: feedTimeout,
+	}
+	fmt.Printf("Polling %s every %s for %s (%s markets)\n", url, interval, raceConfig.Path, marketFamily.name)
+	fmt.Printf("\tStrategy: %s\n", strategy.describe())
+	if len(engine.prices) > 0 {
+		fmt.Printf("\tResuming with %d existing markets\n", len(engine.prices))
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		feedSnapshot, err := pollFeed(client, url)
+		if err != nil {
+			log.Printf("Failed to poll feed: %v", err)
+		} else {
+			quotes := feedSnapshot.Quotes
+			slices.SortFunc(quotes, func (a, b feedQuote) int {
+				return a.Timestamp.Compare(b.Timestamp)
+			})
+			ticks := []marketTick{}
+			for _, quote := range quotes {
+				if !writer.append(quote) {
+					continue
+				}
+				quoteFamily, name, exists := venue.matchMarket(quote.Market)
+				if !exists || quoteFamily.name != marketFamily.name {
+					continue
+				}
+				tick := marketTick{
+					timestamp: quote.Timestamp,
+					market: name,
+					price: quote.Price,
+					quote: quote.getQuote(),
+				}
+				ticks = append(ticks, tick)
+			}
+			if len(engine.prices) == 0 {
+				for _, tick := range ticks {
+					engine.prices[tick.market] = tick
+				}
+			}
+			for _, tick := range ticks {
+				engine.processTick(tick, strategy)
+			}
+			engine.advance(feedSnapshot.Time, strategy)
+			fmt.Printf("%s Received %d new quotes, equity %.4f\n", feedSnapshot.Time.Format(paperLogLayout), len(ticks), engine.getEquity())
+			if feedSnapshot.Time.After(raceConfig.Race.Add(feedRaceDuration)) {
+				fmt.Printf("Race has finished, stopping feed with %d open positions\n", len(engine.positions))
+				return
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func getFeedRace(racePath string) RaceConfiguration {
+	if racePath != "" {
+		return getRaceConfiguration(racePath)
+	}
+	if len(configuration.Races) == 0 {
+		log.Fatal("No races in configuration")
+	}
+	return configuration.Races[len(configuration.Races) - 1]
+}
+
+func pollFeed(client *http.Client, url string) (feedSnapshot, error) {
+	var snapshot feedSnapshot
+	response, err := client.Get(url)
+	if err != nil {
+		return snapshot, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return snapshot, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	err = json.NewDecoder(response.Body).Decode(&snapshot)
+	return snapshot, err
+}
+
+func (q *feedQuote) getQuote() priceQuote {
+	if q.Bid <= 0.0 || q.Ask <= 0.0 {
+		return priceQuote{}
+	}
+	return priceQuote{
+		bid: min(q.Bid, q.Ask),
+		ask: max(q.Bid, q.Ask),
+		exists: true,
+	}
+}
+
+func (w *feedWriter) seed(engine *paperEngine, family string) {
+	for _, path := range getCSVPaths(w.directory, 0) {
+		fileName := filepath.Base(path)
+		series := readPriceSeries(path, w.venue)
+		if len(series) == 0 {
+			continue
+		}
+		point := series[len(series) - 1]
+		w.lastTimestamps[fileName] = point.timestamp
+		marketFamily, name, exists := w.venue.matchMarket(fileName)
+		if !exists || marketFamily.name != family {
+			continue
+		}
+		engine.prices[name] = marketTick{
+			timestamp: point.timestamp,
+			market: name,
+			price: point.price,
+			quote: point.quote,
+		}
+	}
+}
+
+func (w *feedWriter) append(quote feedQuote) bool {
+	fileName := filepath.Base(quote.Market)
+	if filepath.Ext(fileName) != ".csv" {
+		fileName += ".csv"
+	}
+	path := filepath.Join(w.directory, fileName)
+	lastTimestamp, exists := w.lastTimestamps[fileName]
+	if !exists && commons.FileExists(path) {
+		series := readPriceSeries(path, w.venue)
+		if len(series) > 0 {
+			lastTimestamp = series[len(series) - 1].timestamp
+		}
+	}
+	if !quote.Timestamp.After(lastTimestamp) {
+		w.lastTimestamps[fileName] = lastTimestamp
+		return false
+	}
+	writeHeader := !commons.FileExists(path)
+	file, err := os.OpenFile(path, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("Failed to open feed file %s: %v", path, err)
+	}
+	defer file.Close()
+	if writeHeader {
+		fmt.Fprintln(file, feedHeader)
+	}
+	layout := w.venue.TimestampLayout
+	if layout == "" {
+		layout = feedTimestampLayout
+	}
+	bid := ""
+	ask := ""
+	if quote.Bid > 0.0 && quote.Ask > 0.0 {
+		bid = fmt.Sprintf("%.4f", quote.Bid)
+		ask = fmt.Sprintf("%.4f", quote.Ask)
+	}
+	_, err = fmt.Fprintf(file, "%s,%.4f,%s,%s\n", quote.Timestamp.In(w.venue.getLocation()).Format(layout), quote.Price, bid, ask)
+	if err != nil {
+		log.Fatalf("Failed to append to feed file %s: %v", path, err)
+	}
+	w.lastTimestamps[fileName] = quote.Timestamp
+	return true
+}
+
+func runMockServer(address string, racePath string, venueName string, speed float64) {
+	loadConfiguration()
+	raceConfig := getFeedRace(racePath)
+	venue := getVenue(venueName)
+	server := mockServer{
+		series: map[string][]pricePoint{},
+		speed: speed,
+	}
+	for _, path := range getMarketPaths(raceConfig, venue) {
+		series := readPriceSeries(path, venue)
+		if len(series) == 0 {
+			continue
+		}
+		server.series[filepath.Base(path)] = series
+		if server.start.IsZero() || series[0].timestamp.Before(server.start) {
+			server.start = series[0].timestamp
+		}
+	}
+	if len(server.series) == 0 {
+		log.Fatalf("No price series found for %s", raceConfig.Path)
+	}
+	leadStart := raceConfig.Practice.Add(-mockLeadTime)
+	if leadStart.After(server.start) {
+		server.start = leadStart
+	}
+	server.started = time.Now()
+	http.HandleFunc(mockPath, server.handleQuotes)
+	fmt.Printf("Replaying %d markets of %s from %s at %.0fx speed on http://%s%s\n", len(server.series), raceConfig.Path, server.start.Format(paperLogLayout), speed, address, mockPath)
+	err := http.ListenAndServe(address, nil)
+	if err != nil {
+		log.Fatalf("Mock server failed: %v", err)
+	}
+}
+
+func (s *mockServer) handleQuotes(writer http.ResponseWriter, _ *http.Request) {
+	elapsed := time.Duration(float64(time.Since(s.started)) * s.speed)
+	replayTime := s.start.Add(elapsed)
+	snapshot := feedSnapshot{
+		Time: replayTime,
+		Quotes: []feedQuote{},
+	}
+	for fileName, series := range s.series {
+		i, found := slices.BinarySearchFunc(series, replayTime, func (p pricePoint, t time.Time) int {
+			return cmp.Compare(p.timestamp.UnixNano(), t.UnixNano())
+		})
+		if !found {
+			i--
+		}
+		if i < 0 {
+			continue
+		}
+		point := series[i]
+		quote := feedQuote{
+			Market: fileName,
+			Timestamp: point.timestamp,
+			Price: point.price,
+			Bid: point.quote.bid,
+			Ask: point.quote.ask,
+		}
+		snapshot.Quotes = append(snapshot.Quotes, quote)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(writer).Encode(snapshot)
+	if err != nil {
+		log.Printf("Failed to encode quotes: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	fieldAlpha = 0.01
+	fieldIterations = 2000
+	fieldRegularization = 0.01
+)
+
+var driverFeatureNames = []string{
+	"wins",
+	"podiums",
+	"poles",
+	"finishing score",
+	"DNF rate",
+	"won last race",
+}
+
+type fieldRace struct {
+	season int
+	id int
+	drivers []string
+	features [][]float64
+	winner int
+}
+
+type conditionalLogit struct {
+	weights []float64
+}
+
+func performFieldRegression(predictions bool, drivers []driverSeasonalData) {
+	fmt.Printf("\nPer-driver features: %s\n", strings.Join(driverFeatureNames, ", "))
+	races := getFieldRaces(drivers)
+	if !predictions {
+		model := fitConditionalLogit(races)
+		evaluateFieldModel(model, races)
+	} else {
+		makeFieldPredictions(races, drivers)
+	}
+}
+
+func getFieldRaces(drivers []driverSeasonalData) []fieldRace {
+	races := []fieldRace{}
+	for _, driver := range drivers {
+		for k, race := range driver.races {
+			if k < raceWindowSize {
+				continue
+			}
+			features := getDriverFeatures(driver, k)
+			if features == nil {
+				continue
+			}
+			i := slices.IndexFunc(races, func (r fieldRace) bool {
+				return r.season == race.season && r.id == race.id
+			})
+			if i == -1 {
+				newRace := fieldRace{
+					season: race.season,
+					id: race.id,
+					drivers: []string{},
+					features: [][]float64{},
+					winner: -1,
+				}
+				races = append(races, newRace)
+				i = len(races) - 1
+			}
+			current := &races[i]
+			if race.isWin() {
+				current.winner = len(current.drivers)
+			}
+			current.drivers = append(current.drivers, driver.name)
+			current.features = append(current.features, features)
+		}
+	}
+	slices.SortFunc(races, func (a, b fieldRace) int {
+		if a.season != b.season {
+			return cmp.Compare(a.season, b.season)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	return races
+}
+
+func getDriverFeatures(driver driverSeasonalData, k int) []float64 {
+	firstRace := driver.races[k - 1]
+	lastRace := driver.races[k - raceWindowSize]
+	if !enableMultiSeason && firstRace.season != lastRace.season {
+		return nil
+	}
+	wins := 0
+	podiums := 0
+	poles := 0
+	retired := 0
+	score := 0.0
+	for l := 1; l <= raceWindowSize; l++ {
+		race := driver.races[k - l]
+		if race.isWin() {
+			wins++
+		}
+		if race.isPodium() {
+			podiums++
+		}
+		if race.pole {
+			poles++
+		}
+		if race.result == resultRetired {
+			retired++
+		}
+		if race.result == resultPosition {
+			score += 1.0 / float64(race.position)
+		}
+	}
+	wonLastRace := 0.0
+	if firstRace.isWin() {
+		wonLastRace = 1.0
+	}
+	features := []float64{
+		float64(wins),
+		float64(podiums),
+		float64(poles),
+		score,
+		float64(retired) / float64(raceWindowSize),
+		wonLastRace,
+	}
+	return features
+}
+
+func fitConditionalLogit(races []fieldRace) conditionalLogit {
+	model := conditionalLogit{
+		weights: make([]float64, len(driverFeatureNames)),
+	}
+	for range fieldIterations {
+		gradient := make([]float64, len(model.weights))
+		count := 0
+		for _, race := range races {
+			if race.winner == -1 {
+				continue
+			}
+			probabilities := model.predict(race.features)
+			for i, features := range race.features {
+				target := 0.0
+				if i == race.winner {
+					target = 1.0
+				}
+				for j, x := range features {
+					gradient[j] += (target - probabilities[i]) * x
+				}
+			}
+			count++
+		}
+		if count == 0 {
+			log.Fatal("No races with a known winner available for training")
+		}
+		for j := range model.weights {
+			gradient[j] = gradient[j] / float64(count) - fieldRegularization * model.weights[j]
+			model.weights[j] += fieldAlpha * gradient[j]
+		}
+	}
+	return model
+}
+
+func (m *conditionalLogit) predict(features [][]float64) []float64 {
+	utilities := []float64{}
+	maxUtility := math.Inf(-1)
+	for _, x := range features {
+		utility := 0.0
+		for j, value := range x {
+			utility += m.weights[j] * value
+		}
+		utilities = append(utilities, utility)
+		maxUtility = max(maxUtility, utility)
+	}
+	sum := 0.0
+	probabilities := []float64{}
+	for _, utility := range utilities {
+		p := math.Exp(utility - maxUtility)
+		probabilities = append(probabilities, p)
+		sum += p
+	}
+	for i := range probabilities {
+		probabilities[i] /= sum
+	}
+	return probabilities
+}
+
+func evaluateFieldModel(model conditionalLogit, races []fieldRace) {
+	hits := 0
+	count := 0
+	logLoss := 0.0
+	for _, race := range races {
+		if race.winner == -1 {
+			continue
+		}
+		probabilities := model.predict(race.features)
+		favourite := 0
+		for i, p := range probabilities {
+			if p > probabilities[favourite] {
+				favourite = i
+			}
+		}
+		if favourite == race.winner {
+			hits++
+		}
+		logLoss -= math.Log(max(probabilities[race.winner], 1e-15))
+		count++
+	}
+	fmt.Printf("Weights:\n")
+	for i, name := range driverFeatureNames {
+		fmt.Printf("\t%s: %.3f\n", name, model.weights[i])
+	}
+	percentage := 100.0 * float64(hits) / float64(count)
+	fmt.Printf("Favourite won: %.1f%% (%d races)\n", percentage, count)
+	fmt.Printf("Log loss: %.3f\n", logLoss / float64(count))
+}
+
+func makeFieldPredictions(races []fieldRace, drivers []driverSeasonalData) {
+	for id := predictionsId; true; id++ {
+		i := slices.IndexFunc(races, func (r fieldRace) bool {
+			return r.season == predictionsSeason && r.id == id
+		})
+		if i == -1 {
+			break
+		}
+		model := fitConditionalLogit(races[:i])
+		printDistribution(races[i], model)
+	}
+	model := fitConditionalLogit(races)
+	upcomingRace := getUpcomingFieldRace(drivers)
+	fmt.Printf("\nPrediction for upcoming race:\n")
+	if len(upcomingRace.drivers) == 0 {
+		fmt.Printf("No drivers with sufficient history\n")
+		return
+	}
+	printDistribution(upcomingRace, model)
+}
+
+func getUpcomingFieldRace(drivers []driverSeasonalData) fieldRace {
+	upcomingRace := fieldRace{
+		season: lastSeason,
+		id: lastEventID + 1,
+		drivers: []string{},
+		features: [][]float64{},
+		winner: -1,
+	}
+	for _, driver := range drivers {
+		k := slices.IndexFunc(driver.races, func (r driverRaceResult) bool {
+			return r.season == lastSeason && r.id == lastEventID
+		})
+		if k == -1 {
+			continue
+		}
+		features := getDriverFeatures(driver, k + 1)
+		if features == nil {
+			continue
+		}
+		upcomingRace.drivers = append(upcomingRace.drivers, driver.name)
+		upcomingRace.features = append(upcomingRace.features, features)
+	}
+	return upcomingRace
+}
+
+func printDistribution(race fieldRace, model conditionalLogit) {
+	probabilities := model.predict(race.features)
+	indexes := []int{}
+	for i := range race.drivers {
+		indexes = append(indexes, i)
+	}
+	slices.SortFunc(indexes, func (a, b int) int {
+		return cmp.Compare(probabilities[b], probabilities[a])
+	})
+	fmt.Printf("Season = %d, event ID = %d:\n", race.season, race.id)
+	for _, i := range indexes {
+		winnerString := ""
+		if i == race.winner {
+			winnerString = " (winner)"
+		}
+		fmt.Printf("\t%s: %.3f%s\n", race.drivers[i], probabilities[i], winnerString)
+	}
+}
\ No newline at end of file
@@ -2,29 +2,413 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 )
 
+type command struct {
+	name string
+	arguments string
+	description string
+	run func(flags *flag.FlagSet, arguments []string)
+}
+
+var commands = []command{
+	{
+		name: "backtest",
+		description: "Backtest F1 betting strategies",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			family := addFamilyFlag(flags)
+			venue := addVenueFlag(flags)
+			filter, group := addRaceMetadataFlags(flags)
+			policy := flags.String("policy", "", "Snapshot price extraction policy overriding the configuration (last, twap, median, interpolate)")
+			window := flags.Duration("window", 0, "Window of the twap and median snapshot policies")
+			staleness := flags.Duration("staleness", 0, "Maximum age of the last price before a session after which the snapshot is treated as missing")
+			parseFlags(flags, arguments, 0)
+			snapshotPolicy := SnapshotConfiguration{
+				Policy: *policy,
+				Window: *window,
+				MaxStaleness: *staleness,
+			}
+			runBacktest(*family, *venue, *filter, *group, snapshotPolicy)
+		},
+	},
+	{
+		name: "outcomes",
+		description: "Analyze the distribution of outcomes",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			family := addFamilyFlag(flags)
+			venue := addVenueFlag(flags)
+			filter, group := addRaceMetadataFlags(flags)
+			parseFlags(flags, arguments, 0)
+			analyzeOutcomes(*family, *venue, *filter, *group)
+		},
+	},
+	{
+		name: "movements",
+		description: "Analyze price moves between sessions, realised volatility and whether moves predict outcomes or revert",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			family := addFamilyFlag(flags)
+			venue := addVenueFlag(flags)
+			threshold := flags.Float64("threshold", 0.05, "Minimum absolute price move classified as rising or falling")
+			parseFlags(flags, arguments, 0)
+			analyzeMovements(*family, *venue, *threshold)
+		},
+	},
+	{
+		name: "regression",
+		description: "Run regression model on drivers",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			features := addFeaturesFlag(flags)
+			mode := addModeFlag(flags)
+			validation := flags.String("validation", "", "Cross-validate and search hyperparameters, either \"kfold\" or \"time\" for walk-forward folds over seasons and events")
+			folds := flags.Int("folds", 5, "Number of folds used by -validation")
+			backend := addBackendFlag(flags)
+			parseFlags(flags, arguments, 0)
+			options := regressionOptions{
+				featureString: *features,
+				mode: *mode,
+				validation: *validation,
+				folds: *folds,
+				backend: *backend,
+			}
+			performRegression(options)
+		},
+	},
+	{
+		name: "predict",
+		description: "Perform predictions for the upcoming race",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			features := addFeaturesFlag(flags)
+			mode := addModeFlag(flags)
+			backend := addBackendFlag(flags)
+			modelPath := flags.String("model", "", "Path of a model file written by the train command to predict with instead of fitting a new model")
+			parseFlags(flags, arguments, 0)
+			if *modelPath != "" {
+				flags.Visit(func (f *flag.Flag) {
+					if f.Name == "features" || f.Name == "backend" {
+						fmt.Fprintf(os.Stderr, "Flag -%s cannot be combined with -model, the model file determines it\n", f.Name)
+						os.Exit(2)
+					}
+				})
+			}
+			options := regressionOptions{
+				predictions: true,
+				featureString: *features,
+				mode: *mode,
+				backend: *backend,
+				modelPath: *modelPath,
+			}
+			performRegression(options)
+		},
+	},
+	{
+		name: "train",
+		description: "Train a regression model on all available data and write it to a model file",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			features := addFeaturesFlag(flags)
+			backend := addBackendFlag(flags)
+			modelPath := flags.String("model", "", "Path of the model file to write")
+			alphaValue := flags.Float64("alpha", alpha, "Learning rate of the goml backend")
+			regularizationValue := flags.Float64("regularization", regularization, "Regularization of the goml and gonum backends")
+			iterations := flags.Int("iterations", maxIterations, "Maximum number of iterations of the goml and gonum backends")
+			races := flags.Int("races", raceWindowSize, "Number of previous races used to calculate features")
+			parseFlags(flags, arguments, 0)
+			options := regressionOptions{
+				featureString: *features,
+				mode: modePair,
+				backend: *backend,
+				train: true,
+				modelPath: *modelPath,
+				parameters: hyperparameters{
+					backend: *backend,
+					alpha: *alphaValue,
+					regularization: *regularizationValue,
+					maxIterations: *iterations,
+					classThreshold: classThreshold,
+					raceWindowSize: *races,
+				},
+			}
+			performRegression(options)
+		},
+	},
+	{
+		name: "report",
+		arguments: "[<driver>...]",
+		description: "Print a report of the prices of drivers at each snapshot of historical races with their ranks and price changes, filtering for the names specified",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			runReport(flags, arguments, "practice,qualifying,race,final")
+		},
+	},
+	{
+		name: "practice",
+		arguments: "[<driver>...]",
+		description: "Print the pre-practice prices of drivers, an alias of report -columns practice",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			runReport(flags, arguments, "practice")
+		},
+	},
+	{
+		name: "winners",
+		description: "Print the winners of the races in the configuration",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			parseFlags(flags, arguments, 0)
+			printWinners()
+		},
+	},
+	{
+		name: "ratings",
+		description: "Print the history of Elo ratings of drivers and constructors and win probabilities for the upcoming race",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			parseFlags(flags, arguments, 0)
+			printRatings()
+		},
+	},
+	{
+		name: "simulate",
+		arguments: "<ratings|market|model>",
+		description: "Simulate finishing orders of the upcoming race using strengths from ratings, market prices or the per-driver model",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			race := addRaceFlag(flags, "Path of the race in the configuration to use with market strengths, defaults to the last race")
+			snapshot := addSnapshotFlag(flags, "Price snapshot to use with market strengths")
+			parseFlags(flags, arguments, 1)
+			runSimulation(flags.Arg(0), *race, *snapshot)
+		},
+	},
+	{
+		name: "settle",
+		description: "Print settlement data for the races in the configuration derived from Wikipedia results, in the format of the settlements file",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			parseFlags(flags, arguments, 0)
+			printSettlements()
+		},
+	},
+	{
+		name: "compare",
+		description: "Compare prices of a market family across venues and report arbitrage opportunities",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			family := addFamilyFlag(flags)
+			snapshot := addSnapshotFlag(flags, "Price snapshot to compare")
+			parseFlags(flags, arguments, 0)
+			compareVenues(*family, *snapshot)
+		},
+	},
+	{
+		name: "timetable",
+		arguments: "<calendar>",
+		description: "Import session times from an iCal calendar of the F1 schedule and print a diff against the races in the configuration",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			parseFlags(flags, arguments, 1)
+			importTimetable(flags.Arg(0))
+		},
+	},
+	{
+		name: "ingest",
+		description: "Update the local store with market price series, session times and parsed F1 results, re-parsing only files that changed since the last run",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			parseFlags(flags, arguments, 0)
+			ingest()
+		},
+	},
+	{
+		name: "dump",
+		arguments: "<bucket>",
+		description: "Print the JSON records of a bucket of the local store, one per line (races, markets, series, seasons, classifications)",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			parseFlags(flags, arguments, 1)
+			dumpStore(flags.Arg(0))
+		},
+	},
+	{
+		name: "paper",
+		description: "Replay the price archive tick by tick as a live feed and paper trade a strategy",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			race := addRaceFlag(flags, "Path of the race in the configuration to replay, defaults to all races")
+			family := addFamilyFlag(flags)
+			venue := addVenueFlag(flags)
+			snapshot := addSnapshotFlag(flags, "Session at the start of which bets are placed")
+			bets := addBetsFlag(flags)
+			parseFlags(flags, arguments, 0)
+			runPaperTrading(*race, *family, *venue, *snapshot, *bets)
+		},
+	},
+	{
+		name: "feed",
+		arguments: "<url>",
+		description: "Poll a live price feed, appending new quotes to the CSV files of a race and paper trading a strategy at the configured session times",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			race := addRaceFlag(flags, "Path of the race in the configuration to record, defaults to the last race")
+			family := addFamilyFlag(flags)
+			venue := addVenueFlag(flags)
+			snapshot := addSnapshotFlag(flags, "Session at the start of which bets are placed")
+			bets := addBetsFlag(flags)
+			interval := addIntervalFlag(flags)
+			parseFlags(flags, arguments, 1)
+			runFeed(flags.Arg(0), *race, *family, *venue, *snapshot, *bets, *interval)
+		},
+	},
+	{
+		name: "mock",
+		description: "Run a local mock feed server replaying the archived CSV files of a race",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			race := addRaceFlag(flags, "Path of the race in the configuration to replay, defaults to the last race")
+			venue := addVenueFlag(flags)
+			listen := addListenFlag(flags)
+			speed := flags.Float64("speed", 60, "Replay speed relative to real time")
+			parseFlags(flags, arguments, 0)
+			runMockServer(*listen, *race, *venue, *speed)
+		},
+	},
+	{
+		name: "alerts",
+		description: "Watch the CSV files of a race and evaluate the alert rules in the configuration on every new tick",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			race := addRaceFlag(flags, "Path of the race in the configuration to watch, defaults to the last race")
+			interval := addIntervalFlag(flags)
+			parseFlags(flags, arguments, 0)
+			runAlerts(*race, *interval)
+		},
+	},
+	{
+		name: "serve",
+		description: "Start a local HTTP server with JSON endpoints for races, price series, outcomes, backtests and predictions and a dashboard",
+		run: func (flags *flag.FlagSet, arguments []string) {
+			listen := addListenFlag(flags)
+			modelPath := flags.String("model", "", "Path of a model file written by the train command to serve predictions from")
+			features := addFeaturesFlag(flags)
+			backend := addBackendFlag(flags)
+			parseFlags(flags, arguments, 0)
+			runServer(*listen, *modelPath, *features, *backend)
+		},
+	},
+}
+
 func main() {
-	backtest := flag.Bool("backtest", false, "Backtest F1 betting strategies")
-	outcomes := flag.Bool("outcomes", false, "Analyze the distribution of outcomes")
-	regression := flag.Bool("regression", false, "Run regression model on drivers")
-	predict := flag.Bool("predict", false, "Perform predictions")
-	practice := flag.String("practice", "", "Print pre-practice prices of drivers extracted from historical date, filtering for the names specified in the string passed to this argument")
-	win := flag.Bool("win", false, "Can only be used with -practice, enables output of the winner of the race")
+	flag.StringVar(&configurationPath, "config", defaultConfigurationPath, "Path of the configuration file")
+	flag.Usage = printUsage
 	flag.Parse()
-	if *backtest {
-		runBacktest()
-	} else if *outcomes {
-		analyzeOutcomes()
-	} else if *regression {
-		performRegression(false)
-	} else if *predict {
-		performRegression(true)
-	} else if *practice != "" {
-		printPracticePrices(*practice)
-	} else if *win {
-		printWinners()
-	} else {
-		flag.Usage()
+	if flag.NArg() == 0 {
+		printUsage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	for _, command := range commands {
+		if command.name == name {
+			flags := flag.NewFlagSet(command.name, flag.ExitOnError)
+			flags.Usage = func () {
+				printCommandUsage(flags, command)
+			}
+			command.run(flags, flag.Args()[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	output := flag.CommandLine.Output()
+	fmt.Fprintf(output, "Usage: gridlock [-config <path>] <command> [flags]\n\nCommands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(output, "  %-12s %s\n", command.name, command.description)
+	}
+	fmt.Fprintf(output, "\nGlobal flags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(output, "\nRun \"gridlock <command> -h\" for the flags of a command.\n")
+}
+
+func printCommandUsage(flags *flag.FlagSet, command command) {
+	output := flags.Output()
+	usage := []string{"gridlock", command.name, "[flags]"}
+	if command.arguments != "" {
+		usage = append(usage, command.arguments)
+	}
+	fmt.Fprintf(output, "Usage: %s\n\n%s\n", strings.Join(usage, " "), command.description)
+	hasFlags := false
+	flags.VisitAll(func (_ *flag.Flag) {
+		hasFlags = true
+	})
+	if hasFlags {
+		fmt.Fprintf(output, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+}
+
+func runReport(flags *flag.FlagSet, arguments []string, defaultColumns string) {
+	family := addFamilyFlag(flags)
+	venue := addVenueFlag(flags)
+	from := flags.String("from", "", "Path of the first race in the configuration to include")
+	to := flags.String("to", "", "Path of the last race in the configuration to include")
+	season := flags.Int("season", 0, "Only include races of this season")
+	columns := flags.String("columns", defaultColumns, "Comma-separated list of snapshots to print side by side (practice, qualifying, race, final)")
+	winners := flags.Bool("winners", false, "Add a column marking the winners of each race")
+	flags.Parse(arguments)
+	options := reportOptions{
+		drivers: flags.Args(),
+		family: *family,
+		venue: *venue,
+		from: *from,
+		to: *to,
+		season: *season,
+		columns: *columns,
+		winners: *winners,
+	}
+	printPriceReport(options)
+}
+
+func parseFlags(flags *flag.FlagSet, arguments []string, argumentCount int) {
+	flags.Parse(arguments)
+	if flags.NArg() != argumentCount {
+		flags.Usage()
+		os.Exit(2)
 	}
+}
+
+func addFamilyFlag(flags *flag.FlagSet) *string {
+	return flags.String("family", familyWin, "Market family (win, podium, fastest-lap, pole, constructor, head-to-head)")
+}
+
+func addVenueFlag(flags *flag.FlagSet) *string {
+	return flags.String("venue", "", "Name of the venue in the configuration, defaults to the first venue")
+}
+
+func addRaceMetadataFlags(flags *flag.FlagSet) (*string, *string) {
+	filter := flags.String("filter", "", "Comma-separated list of race metadata conditions in the format field=value, e.g. \"season=2024,sprint=true\" (season, round, circuit, country, sprint, weather, track)")
+	group := flags.String("group", "", "Race metadata field to group results by (season, round, circuit, country, sprint, weather, track)")
+	return filter, group
+}
+
+func addRaceFlag(flags *flag.FlagSet, usage string) *string {
+	return flags.String("race", "", usage)
+}
+
+func addSnapshotFlag(flags *flag.FlagSet, usage string) *string {
+	return flags.String("snapshot", "race", usage + " (practice, qualifying, race)")
+}
+
+func addFeaturesFlag(flags *flag.FlagSet) *string {
+	return flags.String("features", "simple", "Comma-separated list of feature sets (simple, combo, grid, decay, dnf, pole, elo)")
+}
+
+func addModeFlag(flags *flag.FlagSet) *string {
+	return flags.String("mode", modePair, "Regression mode, \"pair\" for pairwise labels or \"driver\" for per-driver win probabilities normalised across the field")
+}
+
+func addBackendFlag(flags *flag.FlagSet) *string {
+	return flags.String("backend", backendGoml, "Model backend (goml, gonum, boost, elo)")
+}
+
+func addBetsFlag(flags *flag.FlagSet) *string {
+	return flags.String("bets", "1:no", "Comma-separated list of bets on markets ranked by price, in the format position:yes or position:no")
+}
+
+func addIntervalFlag(flags *flag.FlagSet) *time.Duration {
+	return flags.Duration("interval", 10 * time.Second, "Polling interval")
+}
+
+func addListenFlag(flags *flag.FlagSet) *string {
+	return flags.String("listen", "localhost:8080", "Address the server listens on")
 }
\ No newline at end of file
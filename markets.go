@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+const (
+	familyWin = "win"
+	familyPodium = "podium"
+	familyFastestLap = "fastest-lap"
+	familyPole = "pole"
+	familyConstructor = "constructor"
+	familyHeadToHead = "head-to-head"
+)
+
+type marketFamily struct {
+	name string
+	pattern *regexp.Regexp
+	winners int
+	settle func (finalPrice float64) (bool, bool)
+}
+
+var marketFamilies = []marketFamily{
+	{
+		name: familyPodium,
+		pattern: regexp.MustCompile("will-(.+?)-finish-on-the-podium"),
+		winners: podiumPositions,
+		settle: settleBinary,
+	},
+	{
+		name: familyFastestLap,
+		pattern: regexp.MustCompile("will-(.+?)-(?:get|have|set)-the-fastest-lap"),
+		winners: 1,
+		settle: settleBinary,
+	},
+	{
+		name: familyPole,
+		pattern: regexp.MustCompile("will-(.+?)-(?:get|win|take)-(?:the-)?pole-position"),
+		winners: 1,
+		settle: settleBinary,
+	},
+	{
+		name: familyConstructor,
+		pattern: regexp.MustCompile("will-(.+?)-be-the-(?:winning-constructor|constructor-winner)"),
+		winners: 1,
+		settle: settleBinary,
+	},
+	{
+		name: familyHeadToHead,
+		pattern: regexp.MustCompile("(?:will-)?(.+?-vs-.+?)-(?:head-to-head|h2h|who-will-finish-ahead)"),
+		winners: 0,
+		settle: settleHeadToHead,
+	},
+	{
+		name: familyWin,
+		pattern: regexp.MustCompile("will-(.+?)-win-"),
+		winners: 1,
+		settle: settleBinary,
+	},
+}
+
+func getMarketFamily(name string) marketFamily {
+	for _, family := range marketFamilies {
+		if family.name == name {
+			return family
+		}
+	}
+	names := []string{}
+	for _, family := range marketFamilies {
+		names = append(names, family.name)
+	}
+	log.Fatalf("Unknown market family \"%s\", available families: %s", name, strings.Join(names, ", "))
+	return marketFamily{}
+}
+
+func matchMarketFamily(fileName string) (marketFamily, string, bool) {
+	for _, family := range marketFamilies {
+		matches := family.pattern.FindStringSubmatch(fileName)
+		if matches != nil {
+			return family, matches[1], true
+		}
+	}
+	return marketFamily{}, "", false
+}
+
+func settleBinary(finalPrice float64) (bool, bool) {
+	return finalPrice > winnerPriceLimit, false
+}
+
+func settleHeadToHead(finalPrice float64) (bool, bool) {
+	winner := finalPrice > winnerPriceLimit
+	loser := finalPrice < 1.0 - winnerPriceLimit
+	return winner, !winner && !loser
+}
+
+func (r *raceData) filter(family string, venue string) raceData {
+	drivers := []driverData{}
+	for _, driver := range r.drivers {
+		if driver.family == family && driver.venue == venue && !driver.void {
+			drivers = append(drivers, driver)
+		}
+	}
+	return raceData{
+		name: r.name,
+		metadata: r.metadata,
+		drivers: drivers,
+	}
+}
+
+func filterRaces(races []raceData, family string, venue string) []raceData {
+	filtered := []raceData{}
+	for _, race := range races {
+		familyRace := race.filter(family, venue)
+		if len(familyRace.drivers) > 0 {
+			filtered = append(filtered, familyRace)
+		}
+	}
+	return filtered
+}
\ No newline at end of file
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	metadataSeason = "season"
+	metadataRound = "round"
+	metadataCircuit = "circuit"
+	metadataCountry = "country"
+	metadataSprint = "sprint"
+	metadataWeather = "weather"
+	metadataTrackType = "track"
+)
+
+type RaceMetadata struct {
+	Season int `yaml:"season" json:"season,omitempty"`
+	Round int `yaml:"round" json:"round,omitempty"`
+	Circuit string `yaml:"circuit" json:"circuit,omitempty"`
+	Country string `yaml:"country" json:"country,omitempty"`
+	Sprint bool `yaml:"sprint" json:"sprint,omitempty"`
+	Weather string `yaml:"weather" json:"weather,omitempty"`
+	TrackType string `yaml:"trackType" json:"trackType,omitempty"`
+}
+
+type raceFilter struct {
+	field string
+	value string
+}
+
+type raceGroup struct {
+	name string
+	races []raceData
+}
+
+func (r *RaceConfiguration) validateMetadata() {
+	if r.Season == 0 {
+		r.Season = r.Race.Year()
+	} else if r.Season != r.Race.Year() {
+		log.Fatalf("Season %d does not match the race time in race configuration: %s", r.Season, r.Path)
+	}
+	if r.Round < 0 {
+		log.Fatalf("Invalid round %d in race configuration: %s", r.Round, r.Path)
+	}
+}
+
+func (m *RaceMetadata) getField(field string) string {
+	switch field {
+	case metadataSeason:
+		return strconv.Itoa(m.Season)
+	case metadataRound:
+		if m.Round == 0 {
+			return ""
+		}
+		return strconv.Itoa(m.Round)
+	case metadataCircuit:
+		return m.Circuit
+	case metadataCountry:
+		return m.Country
+	case metadataSprint:
+		return strconv.FormatBool(m.Sprint)
+	case metadataWeather:
+		return m.Weather
+	case metadataTrackType:
+		return m.TrackType
+	default:
+		log.Fatalf("Unknown race metadata field: %s", field)
+	}
+	return ""
+}
+
+func parseRaceFilters(filterString string) []raceFilter {
+	filters := []raceFilter{}
+	if filterString == "" {
+		return filters
+	}
+	for _, token := range strings.Split(filterString, ",") {
+		field, value, found := strings.Cut(strings.TrimSpace(token), "=")
+		if !found {
+			log.Fatalf("Invalid race filter, expected field=value: %s", token)
+		}
+		metadata := RaceMetadata{}
+		_ = metadata.getField(field)
+		filter := raceFilter{
+			field: field,
+			value: value,
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+func filterRaceMetadata(races []raceData, filters []raceFilter) []raceData {
+	filtered := []raceData{}
+	for _, race := range races {
+		match := true
+		for _, filter := range filters {
+			if !strings.EqualFold(race.metadata.getField(filter.field), filter.value) {
+				match = false
+				break
+			}
+		}
+		if match {
+			filtered = append(filtered, race)
+		}
+	}
+	return filtered
+}
+
+func groupRaces(races []raceData, field string) []raceGroup {
+	if field == "" {
+		group := raceGroup{
+			name: "",
+			races: races,
+		}
+		return []raceGroup{group}
+	}
+	groups := []raceGroup{}
+	indexes := map[string]int{}
+	for _, race := range races {
+		name := race.metadata.getField(field)
+		if name == "" {
+			name = "unknown"
+		}
+		i, exists := indexes[name]
+		if !exists {
+			i = len(groups)
+			indexes[name] = i
+			groups = append(groups, raceGroup{
+				name: name,
+				races: []raceData{},
+			})
+		}
+		groups[i].races = append(groups[i].races, race)
+	}
+	return groups
+}
+
+func (g *raceGroup) printHeader(field string) {
+	if field != "" {
+		fmt.Printf("Races with %s %s (%d races):\n\n", field, g.name, len(g.races))
+	}
+}
\ No newline at end of file
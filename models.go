@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+
+	"github.com/cdipaolo/goml/linear"
+	"gonum.org/v1/gonum/optimize"
+)
+
+const (
+	backendGoml = "goml"
+	backendGonum = "gonum"
+	backendBoost = "boost"
+	backendElo = "elo"
+)
+
+type Model interface {
+	fit(features [][]float64, labels []float64, metaData []featureMetaData)
+	predict(features []float64, metaData featureMetaData) float64
+	marshal() json.RawMessage
+	unmarshal(data json.RawMessage)
+}
+
+type gomlModel struct {
+	parameters hyperparameters
+	logistic *linear.Logistic
+}
+
+type gonumModel struct {
+	parameters hyperparameters
+	Weights []float64 `json:"weights"`
+}
+
+func newModel(parameters hyperparameters) Model {
+	switch parameters.backend {
+	case backendGoml:
+		return &gomlModel{
+			parameters: parameters,
+		}
+	case backendGonum:
+		return &gonumModel{
+			parameters: parameters,
+		}
+	case backendBoost:
+		return newBoostedTreeModel(parameters)
+	case backendElo:
+		return newEloModel()
+	default:
+		log.Fatalf("Unknown model backend: %s", parameters.backend)
+	}
+	return nil
+}
+
+func (m *gomlModel) fit(features [][]float64, labels []float64, _ []featureMetaData) {
+	parameters := m.parameters
+	m.logistic = linear.NewLogistic(logisticMethod, parameters.alpha, parameters.regularization, parameters.maxIterations, features, labels)
+	m.logistic.Output = io.Discard
+	err := m.logistic.Learn()
+	if err != nil {
+		log.Fatalf("Failed to train model: %v", err)
+	}
+}
+
+func (m *gomlModel) predict(features []float64, _ featureMetaData) float64 {
+	predictionVector, err := m.logistic.Predict(features)
+	if err != nil {
+		log.Fatalf("Failed to make prediction: %v", err)
+	}
+	return predictionVector[0]
+}
+
+func (m *gomlModel) marshal() json.RawMessage {
+	return marshalModel(m.logistic)
+}
+
+func (m *gomlModel) unmarshal(data json.RawMessage) {
+	parameters := m.parameters
+	m.logistic = linear.NewLogistic(logisticMethod, parameters.alpha, parameters.regularization, parameters.maxIterations, nil, nil)
+	unmarshalModel(data, m.logistic)
+}
+
+func (m *gonumModel) fit(features [][]float64, labels []float64, _ []featureMetaData) {
+	if len(features) == 0 {
+		log.Fatal("Unable to train model without samples")
+	}
+	dimensions := len(features[0]) + 1
+	sampleCount := float64(len(features))
+	problem := optimize.Problem{
+		Func: func (weights []float64) float64 {
+			loss := 0.0
+			for i, x := range features {
+				p := getLogisticProbability(weights, x)
+				p = min(max(p, probabilityEpsilon), 1.0 - probabilityEpsilon)
+				loss -= labels[i] * math.Log(p) + (1.0 - labels[i]) * math.Log(1.0 - p)
+			}
+			loss /= sampleCount
+			for _, weight := range weights[1:] {
+				loss += 0.5 * m.parameters.regularization * weight * weight
+			}
+			return loss
+		},
+		Grad: func (gradient []float64, weights []float64) {
+			for j := range gradient {
+				gradient[j] = 0.0
+			}
+			for i, x := range features {
+				delta := getLogisticProbability(weights, x) - labels[i]
+				gradient[0] += delta
+				for j, value := range x {
+					gradient[j + 1] += delta * value
+				}
+			}
+			for j := range gradient {
+				gradient[j] /= sampleCount
+				if j > 0 {
+					gradient[j] += m.parameters.regularization * weights[j]
+				}
+			}
+		},
+	}
+	settings := &optimize.Settings{
+		MajorIterations: m.parameters.maxIterations,
+	}
+	result, err := optimize.Minimize(problem, make([]float64, dimensions), settings, &optimize.LBFGS{})
+	if err != nil && result == nil {
+		log.Fatalf("Failed to train model: %v", err)
+	}
+	m.Weights = result.X
+}
+
+func (m *gonumModel) predict(features []float64, _ featureMetaData) float64 {
+	if len(features) + 1 != len(m.Weights) {
+		log.Fatalf("Invalid number of features: %d", len(features))
+	}
+	return getLogisticProbability(m.Weights, features)
+}
+
+func (m *gonumModel) marshal() json.RawMessage {
+	return marshalModel(m)
+}
+
+func (m *gonumModel) unmarshal(data json.RawMessage) {
+	unmarshalModel(data, m)
+}
+
+func getLogisticProbability(weights []float64, features []float64) float64 {
+	sum := weights[0]
+	for i, value := range features {
+		sum += weights[i + 1] * value
+	}
+	return 1.0 / (1.0 + math.Exp(-sum))
+}
+
+func marshalModel(model any) json.RawMessage {
+	data, err := json.Marshal(model)
+	if err != nil {
+		log.Fatalf("Failed to serialize model: %v", err)
+	}
+	return data
+}
+
+func unmarshalModel(data json.RawMessage, model any) {
+	err := json.Unmarshal(data, model)
+	if err != nil {
+		log.Fatalf("Failed to deserialize model: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+type movementTransition struct {
+	name string
+	from func (d driverData) (float64, bool)
+	to func (d driverData) (float64, bool)
+	buckets []moveBucket
+}
+
+type moveBucket struct {
+	priceMin float64
+	priceMax float64
+	moves []float64
+}
+
+type volatilityWindow struct {
+	name string
+	volatility []float64
+	hourlyVolatility []float64
+}
+
+type movementGroup struct {
+	name string
+	moves []float64
+	fromPrices []float64
+	toPrices []float64
+	residuals []float64
+	hits int
+}
+
+func analyzeMovements(family string, venueName string, threshold float64) {
+	loadConfiguration()
+	venue := getVenue(venueName)
+	marketFamily := getMarketFamily(family)
+	races := loadRaces()
+	transitions := []movementTransition{
+		newMovementTransition("Practice to qualifying", strategyPractice, strategyQualifying),
+		newMovementTransition("Qualifying to race", strategyQualifying, strategyRace),
+		{
+			name: "Race to final",
+			from: getSnapshotPrice(strategyRace),
+			to: func (d driverData) (float64, bool) {
+				return d.finalPrice, true
+			},
+			buckets: newMoveBuckets(),
+		},
+	}
+	windows := []volatilityWindow{
+		{
+			name: "Before practice",
+		},
+		{
+			name: "Practice to qualifying",
+		},
+		{
+			name: "Qualifying to race",
+		},
+		{
+			name: "Race to settlement",
+		},
+	}
+	practiceGroups := newMovementGroups(threshold)
+	qualifyingGroups := newMovementGroups(threshold)
+	practiceMoves := []float64{}
+	practiceResiduals := []float64{}
+	qualifyingMoves := []float64{}
+	qualifyingResiduals := []float64{}
+	db := openCache()
+	defer closeStore(db)
+	for i, raceConfig := range configuration.Races {
+		race := races[i].filter(marketFamily.name, venue.Name)
+		sessionTimes := []time.Time{
+			raceConfig.Practice.Time,
+			raceConfig.Qualifying.Time,
+			raceConfig.Race.Time,
+		}
+		for _, driver := range race.drivers {
+			for j := range transitions {
+				transitions[j].add(driver)
+			}
+			series := getMarketSeries(db, raceConfig, venue, driver)
+			for j := range windows {
+				start := time.Time{}
+				end := time.Time{}
+				if j > 0 {
+					start = sessionTimes[j - 1]
+				}
+				if j < len(sessionTimes) {
+					end = sessionTimes[j]
+				}
+				windows[j].add(series, start, end)
+			}
+			outcome := 0.0
+			if driver.winner {
+				outcome = 1.0
+			}
+			if !driver.practiceMissing && !driver.qualifyingMissing {
+				move := driver.qualifyingPrice - driver.practicePrice
+				residual := outcome - driver.practicePrice
+				addMovement(practiceGroups, threshold, move, driver.practicePrice, driver.qualifyingPrice, residual, driver.winner)
+				practiceMoves = append(practiceMoves, move)
+				practiceResiduals = append(practiceResiduals, residual)
+			}
+			if !driver.qualifyingMissing && !driver.raceMissing {
+				move := driver.racePrice - driver.qualifyingPrice
+				residual := outcome - driver.racePrice
+				addMovement(qualifyingGroups, threshold, move, driver.qualifyingPrice, driver.racePrice, residual, driver.winner)
+				qualifyingMoves = append(qualifyingMoves, move)
+				qualifyingResiduals = append(qualifyingResiduals, residual)
+			}
+		}
+	}
+	fmt.Printf("Average absolute moves by price bucket (%s markets):\n", marketFamily.name)
+	for _, transition := range transitions {
+		transition.print()
+	}
+	fmt.Printf("\nRealised volatility per session window:\n")
+	for _, window := range windows {
+		window.print()
+	}
+	fmt.Printf("\nMoves after practice and outcomes (residual = outcome - practice price):\n")
+	printMovementGroups(practiceGroups, "practice", "qualifying")
+	printCorrelation(practiceMoves, practiceResiduals, "positive values indicate that moves after practice predict the outcome")
+	fmt.Printf("\nMoves between qualifying and race and outcomes (residual = outcome - race price):\n")
+	printMovementGroups(qualifyingGroups, "qualifying", "race")
+	printCorrelation(qualifyingMoves, qualifyingResiduals, "negative values indicate overreaction and mean reversion")
+}
+
+func newMovementTransition(name string, from strategyType, to strategyType) movementTransition {
+	return movementTransition{
+		name: name,
+		from: getSnapshotPrice(from),
+		to: getSnapshotPrice(to),
+		buckets: newMoveBuckets(),
+	}
+}
+
+func getSnapshotPrice(stratType strategyType) func (d driverData) (float64, bool) {
+	return func (d driverData) (float64, bool) {
+		return d.getPrice(stratType), !d.isMissing(stratType)
+	}
+}
+
+func newMoveBuckets() []moveBucket {
+	limits := []float64{0.00, 0.025, 0.05, 0.10, 0.20, 0.30, 0.40, 1.00}
+	buckets := []moveBucket{}
+	for i := 0; i < len(limits) - 1; i++ {
+		bucket := moveBucket{
+			priceMin: limits[i],
+			priceMax: limits[i + 1],
+			moves: []float64{},
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+func (t *movementTransition) add(driver driverData) {
+	from, fromExists := t.from(driver)
+	to, toExists := t.to(driver)
+	if !fromExists || !toExists {
+		return
+	}
+	for i := range t.buckets {
+		bucket := &t.buckets[i]
+		if from >= bucket.priceMin && from < bucket.priceMax {
+			bucket.moves = append(bucket.moves, to - from)
+			return
+		}
+	}
+}
+
+func (t *movementTransition) print() {
+	fmt.Printf("\t%s:\n", t.name)
+	for _, bucket := range t.buckets {
+		count := len(bucket.moves)
+		if count == 0 {
+			fmt.Printf("\t\t%.3f - %.3f: -\n", bucket.priceMin, bucket.priceMax)
+			continue
+		}
+		absoluteMoves := []float64{}
+		for _, move := range bucket.moves {
+			absoluteMoves = append(absoluteMoves, math.Abs(move))
+		}
+		fmt.Printf("\t\t%.3f - %.3f: %.3f (mean %+.3f, %d samples)\n", bucket.priceMin, bucket.priceMax, stat.Mean(absoluteMoves, nil), stat.Mean(bucket.moves, nil), count)
+	}
+}
+
+func (w *volatilityWindow) add(series []pricePoint, start time.Time, end time.Time) {
+	var previous *pricePoint
+	var first *pricePoint
+	sumSquares := 0.0
+	changes := 0
+	for i := range series {
+		point := &series[i]
+		if !start.IsZero() && point.timestamp.Before(start) {
+			continue
+		}
+		if !end.IsZero() && point.timestamp.After(end) {
+			break
+		}
+		if previous != nil {
+			change := point.price - previous.price
+			sumSquares += change * change
+			changes++
+		} else {
+			first = point
+		}
+		previous = point
+	}
+	if changes == 0 {
+		return
+	}
+	w.volatility = append(w.volatility, math.Sqrt(sumSquares))
+	hours := previous.timestamp.Sub(first.timestamp).Hours()
+	if hours > 0.0 {
+		w.hourlyVolatility = append(w.hourlyVolatility, math.Sqrt(sumSquares / hours))
+	}
+}
+
+func (w *volatilityWindow) print() {
+	if len(w.volatility) == 0 {
+		fmt.Printf("\t%s: -\n", w.name)
+		return
+	}
+	fmt.Printf("\t%s: %.4f (%.4f per hour, %d markets)\n", w.name, stat.Mean(w.volatility, nil), stat.Mean(w.hourlyVolatility, nil), len(w.volatility))
+}
+
+func newMovementGroups(threshold float64) []movementGroup {
+	return []movementGroup{
+		{
+			name: fmt.Sprintf("Rising (%+.3f or more)", threshold),
+		},
+		{
+			name: "Flat",
+		},
+		{
+			name: fmt.Sprintf("Falling (%+.3f or less)", -threshold),
+		},
+	}
+}
+
+func addMovement(groups []movementGroup, threshold float64, move float64, from float64, to float64, residual float64, winner bool) {
+	var group *movementGroup
+	if move >= threshold {
+		group = &groups[0]
+	} else if move <= -threshold {
+		group = &groups[2]
+	} else {
+		group = &groups[1]
+	}
+	group.moves = append(group.moves, move)
+	group.fromPrices = append(group.fromPrices, from)
+	group.toPrices = append(group.toPrices, to)
+	group.residuals = append(group.residuals, residual)
+	if winner {
+		group.hits++
+	}
+}
+
+func printMovementGroups(groups []movementGroup, fromName string, toName string) {
+	for _, group := range groups {
+		count := len(group.moves)
+		if count == 0 {
+			fmt.Printf("\t%s: -\n", group.name)
+			continue
+		}
+		winRate := 100.0 * float64(group.hits) / float64(count)
+		fmt.Printf("\t%s: %d samples, mean move %+.3f, mean %s price %.3f, mean %s price %.3f, win rate %.1f%%, mean residual %+.3f\n", group.name, count, stat.Mean(group.moves, nil), fromName, stat.Mean(group.fromPrices, nil), toName, stat.Mean(group.toPrices, nil), winRate, stat.Mean(group.residuals, nil))
+	}
+}
+
+func printCorrelation(moves []float64, residuals []float64, interpretation string) {
+	correlation := math.NaN()
+	if len(moves) >= 2 {
+		correlation = stat.Correlation(moves, residuals, nil)
+	}
+	if math.IsNaN(correlation) {
+		fmt.Printf("\tCorrelation of move and residual: -\n")
+		return
+	}
+	fmt.Printf("\tCorrelation of move and residual: %.3f (%s)\n", correlation, interpretation)
+}
\ No newline at end of file
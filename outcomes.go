@@ -18,22 +18,43 @@ type priceBin struct {
 	hits int
 }
 
-func analyzeOutcomes() {
+func analyzeOutcomes(family string, venueName string, filterString string, groupField string) {
 	loadConfiguration()
+	filters := parseRaceFilters(filterString)
 	races := loadRaces()
+	venue := getVenue(venueName)
+	races = filterRaces(races, getMarketFamily(family).name, venue.Name)
+	races = filterRaceMetadata(races, filters)
+	for _, raceGroup := range groupRaces(races, groupField) {
+		raceGroup.printHeader(groupField)
+		for _, group := range getOutcomeGroups(raceGroup.races) {
+			group.print()
+		}
+	}
+}
+
+func getOutcomeGroups(races []raceData) []priceBinGroup {
 	practiceGroup := newBinGroup("Practice")
 	qualifyingGroup := newBinGroup("Qualifying")
 	raceGroup := newBinGroup("Race")
 	for _, race := range races {
 		for _, driver := range race.drivers {
-			practiceGroup.add(driver.practicePrice, driver.winner)
-			qualifyingGroup.add(driver.qualifyingPrice, driver.winner)
-			raceGroup.add(driver.racePrice, driver.winner)
+			if !driver.practiceMissing {
+				practiceGroup.add(driver.practicePrice, driver.winner)
+			}
+			if !driver.qualifyingMissing {
+				qualifyingGroup.add(driver.qualifyingPrice, driver.winner)
+			}
+			if !driver.raceMissing {
+				raceGroup.add(driver.racePrice, driver.winner)
+			}
 		}
 	}
-	practiceGroup.print()
-	qualifyingGroup.print()
-	raceGroup.print()
+	return []priceBinGroup{
+		practiceGroup,
+		qualifyingGroup,
+		raceGroup,
+	}
 }
 
 func newBinGroup(name string) priceBinGroup {
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	paperLogLayout = "2006-01-02 15:04:05"
+)
+
+type marketTick struct {
+	timestamp time.Time
+	market string
+	price float64
+	quote priceQuote
+}
+
+type paperStrategy interface {
+	onSession(engine *paperEngine, session strategyType)
+	onTick(engine *paperEngine, tick marketTick)
+	describe() string
+}
+
+type paperOrder struct {
+	market string
+	yes bool
+	stake float64
+	close bool
+	submitted time.Time
+}
+
+type paperFill struct {
+	timestamp time.Time
+	market string
+	yes bool
+	close bool
+	shares float64
+	price float64
+}
+
+type paperPosition struct {
+	market string
+	yes bool
+	shares float64
+	cost float64
+}
+
+type paperEngine struct {
+	venue VenueConfiguration
+	execution executionModel
+	cash float64
+	now time.Time
+	sessionTimes []time.Time
+	nextSession int
+	prices map[string]marketTick
+	orders []paperOrder
+	fills []paperFill
+	positions map[string]*paperPosition
+}
+
+type rankStrategy struct {
+	session strategyType
+	bets []strategyBet
+}
+
+func runPaperTrading(racePath string, family string, venueName string, snapshot string, betsString string) {
+	loadConfiguration()
+	venue := getVenue(venueName)
+	marketFamily := getMarketFamily(family)
+	stratType := getStrategyType(snapshot)
+	bets := parseBets(betsString)
+	raceConfigs := configuration.Races
+	if racePath != "" {
+		raceConfigs = []RaceConfiguration{getRaceConfiguration(racePath)}
+	}
+	db := openCache()
+	defer closeStore(db)
+	total := 0.0
+	for _, raceConfig := range raceConfigs {
+		race := loadRace(db, raceConfig)
+		race = race.filter(marketFamily.name, venue.Name)
+		if len(race.drivers) == 0 {
+			continue
+		}
+		ticks := getRaceTicks(db, raceConfig, venue, race)
+		strategy := &rankStrategy{
+			session: stratType,
+			bets: bets,
+		}
+		engine := newPaperEngine(venue, raceConfig)
+		fmt.Printf("Paper trading %s (%s markets, %d ticks):\n", race.name, marketFamily.name, len(ticks))
+		fmt.Printf("\tStrategy: %s\n", strategy.describe())
+		fmt.Printf("\tVenue: %s\n", venue.describe())
+		returns := engine.replay(ticks, strategy, race)
+		total += returns
+		fmt.Printf("\tReturns: %+.1f%%\n\n", 100.0 * returns)
+	}
+	fmt.Printf("Total returns: %+.1f%%\n", 100.0 * total)
+}
+
+func parseBets(betsString string) []strategyBet {
+	bets := []strategyBet{}
+	for _, token := range strings.Split(betsString, ",") {
+		tokens := strings.Split(strings.TrimSpace(token), ":")
+		if len(tokens) != 2 {
+			log.Fatalf("Invalid bet, expected position:yes or position:no: %s", token)
+		}
+		position, err := strconv.Atoi(tokens[0])
+		if err != nil || position < 1 {
+			log.Fatalf("Invalid bet position: %s", tokens[0])
+		}
+		var yes bool
+		switch tokens[1] {
+		case "yes":
+			yes = true
+		case "no":
+			yes = false
+		default:
+			log.Fatalf("Invalid bet side, expected yes or no: %s", tokens[1])
+		}
+		bet := strategyBet{
+			position: position,
+			yes: yes,
+		}
+		bets = append(bets, bet)
+	}
+	return bets
+}
+
+func getRaceTicks(db *bolt.DB, raceConfig RaceConfiguration, venue VenueConfiguration, race raceData) []marketTick {
+	ticks := []marketTick{}
+	for _, path := range getMarketPaths(raceConfig, venue) {
+		fileName := filepath.Base(path)
+		family, name, exists := venue.matchMarket(fileName)
+		if !exists {
+			continue
+		}
+		isMarket := slices.ContainsFunc(race.drivers, func (d driverData) bool {
+			return d.family == family.name && d.name == name
+		})
+		if !isMarket {
+			continue
+		}
+		series := loadSeries(db, getSeriesKey(raceConfig.Path, venue.Name, fileName))
+		if series == nil {
+			series = readPriceSeries(path, venue)
+		}
+		for _, point := range series {
+			tick := marketTick{
+				timestamp: point.timestamp,
+				market: name,
+				price: point.price,
+				quote: point.quote,
+			}
+			ticks = append(ticks, tick)
+		}
+	}
+	slices.SortStableFunc(ticks, func (a, b marketTick) int {
+		return a.timestamp.Compare(b.timestamp)
+	})
+	return ticks
+}
+
+func newPaperEngine(venue VenueConfiguration, raceConfig RaceConfiguration) *paperEngine {
+	return &paperEngine{
+		venue: venue,
+		sessionTimes: []time.Time{
+			raceConfig.Practice.Time,
+			raceConfig.Qualifying.Time,
+			raceConfig.Race.Time,
+		},
+		execution: venue.getExecutionModel(),
+		cash: 1.0,
+		prices: map[string]marketTick{},
+		orders: []paperOrder{},
+		fills: []paperFill{},
+		positions: map[string]*paperPosition{},
+	}
+}
+
+func (e *paperEngine) replay(ticks []marketTick, strategy paperStrategy, race raceData) float64 {
+	for _, tick := range ticks {
+		e.processTick(tick, strategy)
+	}
+	for _, order := range e.orders {
+		fmt.Printf("\tCancelled unfilled order for %s submitted at %s\n", order.market, order.submitted.Format(paperLogLayout))
+	}
+	e.orders = []paperOrder{}
+	e.settle(race)
+	return e.cash - 1.0
+}
+
+func (e *paperEngine) processTick(tick marketTick, strategy paperStrategy) {
+	e.advance(tick.timestamp, strategy)
+	e.fillOrders(tick)
+	e.prices[tick.market] = tick
+	strategy.onTick(e, tick)
+}
+
+func (e *paperEngine) advance(now time.Time, strategy paperStrategy) {
+	e.now = now
+	sessions := []strategyType{
+		strategyPractice,
+		strategyQualifying,
+		strategyRace,
+	}
+	for e.nextSession < len(sessions) && now.After(e.sessionTimes[e.nextSession]) {
+		session := sessions[e.nextSession]
+		fmt.Printf("\t%s Start of %s, equity %.4f\n", now.Format(paperLogLayout), getStrategyTypeString(session), e.getEquity())
+		strategy.onSession(e, session)
+		e.nextSession++
+	}
+}
+
+func (e *paperEngine) placeOrder(market string, yes bool, stake float64) {
+	order := paperOrder{
+		market: market,
+		yes: yes,
+		stake: stake,
+		submitted: e.now,
+	}
+	e.orders = append(e.orders, order)
+}
+
+func (e *paperEngine) closePosition(market string) {
+	position, exists := e.positions[market]
+	if !exists {
+		return
+	}
+	pending := slices.ContainsFunc(e.orders, func (o paperOrder) bool {
+		return o.market == market && o.close
+	})
+	if pending {
+		return
+	}
+	order := paperOrder{
+		market: market,
+		yes: position.yes,
+		close: true,
+		submitted: e.now,
+	}
+	e.orders = append(e.orders, order)
+}
+
+func (e *paperEngine) fillOrders(tick marketTick) {
+	remaining := []paperOrder{}
+	for _, order := range e.orders {
+		if order.market != tick.market {
+			remaining = append(remaining, order)
+			continue
+		}
+		price, quote := getSidePrice(tick, order.yes)
+		fill := paperFill{
+			timestamp: tick.timestamp,
+			market: order.market,
+			yes: order.yes,
+			close: order.close,
+		}
+		if order.close {
+			position := e.positions[order.market]
+			value := position.shares * price
+			fill.price = 1.0 - e.execution.getFillPrice(1.0 - price, quote.invert(), value * backtestBankroll)
+			fill.shares = position.shares
+			e.cash += fill.shares * fill.price - e.venue.getTradeFee()
+			delete(e.positions, order.market)
+		} else {
+			fill.price = e.execution.getFillPrice(price, quote, order.stake * backtestBankroll)
+			fill.shares = order.stake / fill.price
+			e.cash -= order.stake + e.venue.getTradeFee()
+			position, exists := e.positions[order.market]
+			if !exists {
+				position = &paperPosition{
+					market: order.market,
+					yes: order.yes,
+				}
+				e.positions[order.market] = position
+			}
+			position.shares += fill.shares
+			position.cost += order.stake
+		}
+		e.fills = append(e.fills, fill)
+		fill.print()
+	}
+	e.orders = remaining
+}
+
+func (e *paperEngine) settle(race raceData) {
+	markets := []string{}
+	for market := range e.positions {
+		markets = append(markets, market)
+	}
+	slices.Sort(markets)
+	for _, market := range markets {
+		position := e.positions[market]
+		driver, exists := race.getDriver(market)
+		if !exists {
+			log.Fatalf("Unable to settle position in unknown market %s of %s", market, race.name)
+		}
+		var payout float64
+		if driver.void {
+			payout = position.cost
+		} else if driver.winner == position.yes {
+			profit := position.shares - position.cost
+			payout = position.cost + profit * (1.0 - e.venue.WinningsFee)
+		}
+		e.cash += payout
+		fmt.Printf("\tSettled %s %s: paid %.4f, received %.4f\n", getOutcomeString(position.yes, false), market, position.cost, payout)
+	}
+	e.positions = map[string]*paperPosition{}
+}
+
+func (e *paperEngine) getEquity() float64 {
+	equity := e.cash
+	for _, position := range e.positions {
+		tick, exists := e.prices[position.market]
+		if !exists {
+			equity += position.cost
+			continue
+		}
+		price, _ := getSidePrice(tick, position.yes)
+		equity += position.shares * price
+	}
+	return equity
+}
+
+func (e *paperEngine) getRankedMarkets() []marketTick {
+	ticks := []marketTick{}
+	for _, tick := range e.prices {
+		ticks = append(ticks, tick)
+	}
+	slices.SortFunc(ticks, func (a, b marketTick) int {
+		return cmp.Or(cmp.Compare(b.price, a.price), cmp.Compare(a.market, b.market))
+	})
+	return ticks
+}
+
+func getSidePrice(tick marketTick, yes bool) (float64, priceQuote) {
+	if yes {
+		return tick.price, tick.quote
+	}
+	return 1.0 - tick.price, tick.quote.invert()
+}
+
+func (f *paperFill) print() {
+	action := "Bought"
+	if f.close {
+		action = "Sold"
+	}
+	fmt.Printf("\t%s %s %.2f %s shares of %s at %.3f\n", f.timestamp.Format(paperLogLayout), action, f.shares, getOutcomeString(f.yes, false), f.market, f.price)
+}
+
+func (r *raceData) getDriver(name string) (driverData, bool) {
+	i := slices.IndexFunc(r.drivers, func (d driverData) bool {
+		return d.name == name
+	})
+	if i == -1 {
+		return driverData{}, false
+	}
+	return r.drivers[i], true
+}
+
+func (s *rankStrategy) onSession(engine *paperEngine, session strategyType) {
+	if session != s.session {
+		return
+	}
+	markets := engine.getRankedMarkets()
+	stake := positionSize / float64(len(s.bets))
+	for _, bet := range s.bets {
+		i := bet.position - 1
+		if i >= len(markets) {
+			log.Printf("Not enough markets for bet position %d", bet.position)
+			continue
+		}
+		engine.placeOrder(markets[i].market, bet.yes, stake)
+	}
+}
+
+func (s *rankStrategy) onTick(engine *paperEngine, tick marketTick) {
+	if !enableStopLoss {
+		return
+	}
+	position, exists := engine.positions[tick.market]
+	if !exists {
+		return
+	}
+	price, _ := getSidePrice(tick, position.yes)
+	if position.shares * price < (1.0 - stopLoss) * position.cost {
+		engine.closePosition(tick.market)
+	}
+}
+
+func (s *rankStrategy) describe() string {
+	bets := []string{}
+	for _, bet := range s.bets {
+		bets = append(bets, fmt.Sprintf("%d:%s", bet.position, getOutcomeString(bet.yes, false)))
+	}
+	return fmt.Sprintf("ranked bets %s at the start of %s", strings.Join(bets, ", "), getStrategyTypeString(s.session))
+}
\ No newline at end of file
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	modelFileVersion = 1
+)
+
+type modelFile struct {
+	Version int `json:"version"`
+	Created time.Time `json:"created"`
+	FeatureSet string `json:"featureSet"`
+	FeatureNames []string `json:"featureNames"`
+	Hyperparameters modelHyperparameters `json:"hyperparameters"`
+	TrainingWindow modelTrainingWindow `json:"trainingWindow"`
+	DataHash string `json:"dataHash"`
+	Model json.RawMessage `json:"model"`
+}
+
+type modelHyperparameters struct {
+	Backend string `json:"backend"`
+	Alpha float64 `json:"alpha"`
+	Regularization float64 `json:"regularization"`
+	MaxIterations int `json:"maxIterations"`
+	ClassThreshold float64 `json:"classThreshold"`
+	RaceWindowSize int `json:"raceWindowSize"`
+}
+
+type modelTrainingWindow struct {
+	FirstSeason int `json:"firstSeason"`
+	FirstID int `json:"firstId"`
+	LastSeason int `json:"lastSeason"`
+	LastID int `json:"lastId"`
+	Samples int `json:"samples"`
+}
+
+func trainModel(options regressionOptions, drivers []driverSeasonalData) {
+	if options.modelPath == "" {
+		log.Fatal("No model path specified, use -model")
+	}
+	sets := getFeatureSets(options.featureString)
+	if len(sets) != 1 {
+		log.Fatalf("Exactly one feature set must be specified for training: %s", options.featureString)
+	}
+	set := sets[0]
+	parameters := options.parameters
+	if parameters.alpha <= 0.0 || parameters.regularization < 0.0 || parameters.maxIterations < 1 || parameters.raceWindowSize < 1 {
+		log.Fatalf("Invalid hyperparameters for training: alpha %g, regularization %g, %d iterations, %d races", parameters.alpha, parameters.regularization, parameters.maxIterations, parameters.raceWindowSize)
+	}
+	features, labels, metaData := getFeatures(drivers, set, parameters.raceWindowSize)
+	if len(features) == 0 {
+		log.Fatalf("No samples available for feature set \"%s\"", set.name)
+	}
+	model := newModel(parameters)
+	model.fit(features, labels, metaData)
+	window := modelTrainingWindow{
+		FirstSeason: metaData[0].season,
+		FirstID: metaData[0].id,
+		LastSeason: metaData[0].season,
+		LastID: metaData[0].id,
+		Samples: len(features),
+	}
+	for _, currentMetaData := range metaData {
+		if currentMetaData.season < window.FirstSeason || (currentMetaData.season == window.FirstSeason && currentMetaData.id < window.FirstID) {
+			window.FirstSeason = currentMetaData.season
+			window.FirstID = currentMetaData.id
+		}
+		if currentMetaData.season > window.LastSeason || (currentMetaData.season == window.LastSeason && currentMetaData.id > window.LastID) {
+			window.LastSeason = currentMetaData.season
+			window.LastID = currentMetaData.id
+		}
+	}
+	output := modelFile{
+		Version: modelFileVersion,
+		Created: time.Now().UTC(),
+		FeatureSet: set.name,
+		FeatureNames: set.featureNames,
+		Hyperparameters: modelHyperparameters{
+			Backend: parameters.backend,
+			Alpha: parameters.alpha,
+			Regularization: parameters.regularization,
+			MaxIterations: parameters.maxIterations,
+			ClassThreshold: parameters.classThreshold,
+			RaceWindowSize: parameters.raceWindowSize,
+		},
+		TrainingWindow: window,
+		DataHash: getDataHash(features, labels, metaData),
+		Model: model.marshal(),
+	}
+	data, err := json.MarshalIndent(output, "", "\t")
+	if err != nil {
+		log.Fatalf("Failed to serialize model file: %v", err)
+	}
+	err = os.WriteFile(options.modelPath, data, 0644)
+	if err != nil {
+		log.Fatalf("Failed to write model file: %v", err)
+	}
+	fmt.Printf("Trained %s model on %d samples with feature set \"%s\" and wrote it to %s\n", parameters.backend, len(features), set.name, options.modelPath)
+}
+
+func predictFromModel(path string, drivers []driverSeasonalData) {
+	set, model, input := loadModel(path)
+	parameters := input.Hyperparameters
+	window := input.TrainingWindow
+	fmt.Printf("Loaded %s model from %s\n", parameters.Backend, path)
+	fmt.Printf("Feature set \"%s\": %s\n", set.name, strings.Join(set.featureNames, ", "))
+	format := "Training window: season %d event ID %d to season %d event ID %d (%d samples, data hash %s)\n"
+	fmt.Printf(format, window.FirstSeason, window.FirstID, window.LastSeason, window.LastID, window.Samples, input.DataHash)
+	printUpcomingPredictions(set, drivers, model, parameters.RaceWindowSize)
+}
+
+func loadModel(path string) (featureSet, Model, modelFile) {
+	input := loadModelFile(path)
+	set := getFeatureSet(input.FeatureSet)
+	if strings.Join(set.featureNames, ",") != strings.Join(input.FeatureNames, ",") {
+		log.Fatalf("Features of set \"%s\" do not match those of model file %s", set.name, path)
+	}
+	stored := input.Hyperparameters
+	parameters := hyperparameters{
+		backend: stored.Backend,
+		alpha: stored.Alpha,
+		regularization: stored.Regularization,
+		maxIterations: stored.MaxIterations,
+		classThreshold: stored.ClassThreshold,
+		raceWindowSize: stored.RaceWindowSize,
+	}
+	model := newModel(parameters)
+	model.unmarshal(input.Model)
+	return set, model, input
+}
+
+func loadModelFile(path string) modelFile {
+	data := commons.ReadFile(path)
+	var input modelFile
+	err := json.Unmarshal(data, &input)
+	if err != nil {
+		log.Fatalf("Failed to parse model file %s: %v", path, err)
+	}
+	if input.Version != modelFileVersion {
+		log.Fatalf("Unsupported model file version in %s: %d", path, input.Version)
+	}
+	return input
+}
+
+func getDataHash(features [][]float64, labels []float64, metaData []featureMetaData) string {
+	hash := sha256.New()
+	for i, currentFeatures := range features {
+		currentMetaData := metaData[i]
+		fmt.Fprintf(hash, "%s|%s|%d|%d|%v|%g\n", currentMetaData.driver1, currentMetaData.driver2, currentMetaData.season, currentMetaData.id, currentFeatures, labels[i])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
\ No newline at end of file
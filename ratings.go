@@ -0,0 +1,206 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+const (
+	ratingFactor = 24.0
+)
+
+type ratingSystem struct {
+	drivers map[string]float64
+	constructors map[string]float64
+	history []ratingSnapshot
+}
+
+type ratingSnapshot struct {
+	season int
+	id int
+	field []string
+	drivers map[string]float64
+	constructors map[string]float64
+}
+
+type ratingEntry struct {
+	name string
+	rating float64
+}
+
+func printRatings() {
+	_, classifications := loadClassifications()
+	ratings := computeRatings(classifications)
+	var previous *ratingSnapshot
+	for i := range ratings.history {
+		snapshot := &ratings.history[i]
+		fmt.Printf("Season = %d, event ID = %d:\n", snapshot.season, snapshot.id)
+		for _, entry := range getSortedRatings(snapshot.drivers, snapshot.field) {
+			delta := 0.0
+			if previous != nil {
+				previousRating, exists := previous.drivers[entry.name]
+				if exists {
+					delta = entry.rating - previousRating
+				}
+			}
+			fmt.Printf("\t%s: %.0f (%+.0f)\n", entry.name, entry.rating, delta)
+		}
+		previous = snapshot
+	}
+	if previous == nil {
+		return
+	}
+	fmt.Printf("\nConstructor ratings:\n")
+	for _, entry := range getSortedRatings(ratings.constructors, nil) {
+		fmt.Printf("\t%s: %.0f\n", entry.name, entry.rating)
+	}
+	fmt.Printf("\nWin probabilities for upcoming race:\n")
+	probabilities := ratings.getWinProbabilities(previous.field)
+	for _, entry := range getSortedRatings(probabilities, previous.field) {
+		fmt.Printf("\t%s: %.3f\n", entry.name, entry.rating)
+	}
+}
+
+func computeRatings(classifications []raceClassification) ratingSystem {
+	ordered := slices.Clone(classifications)
+	slices.SortFunc(ordered, func (a, b raceClassification) int {
+		if a.season != b.season {
+			return cmp.Compare(a.season, b.season)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	ratings := ratingSystem{
+		drivers: map[string]float64{},
+		constructors: map[string]float64{},
+		history: []ratingSnapshot{},
+	}
+	for _, classification := range ordered {
+		drivers := []string{}
+		driverRanks := []int{}
+		constructors := []string{}
+		constructorRanks := []int{}
+		for i, entry := range classification.entries {
+			rank := getClassificationRank(entry, i, len(classification.entries))
+			drivers = append(drivers, entry.driver)
+			driverRanks = append(driverRanks, rank)
+			j := slices.Index(constructors, entry.constructor)
+			if j == -1 {
+				constructors = append(constructors, entry.constructor)
+				constructorRanks = append(constructorRanks, rank)
+			} else {
+				constructorRanks[j] = min(constructorRanks[j], rank)
+			}
+		}
+		updateRatings(ratings.drivers, drivers, driverRanks)
+		updateRatings(ratings.constructors, constructors, constructorRanks)
+		snapshot := ratingSnapshot{
+			season: classification.season,
+			id: classification.id,
+			field: drivers,
+			drivers: maps.Clone(ratings.drivers),
+			constructors: maps.Clone(ratings.constructors),
+		}
+		ratings.history = append(ratings.history, snapshot)
+	}
+	return ratings
+}
+
+func getClassificationRank(entry classificationEntry, index int, fieldSize int) int {
+	if entry.result == resultPosition {
+		return entry.position
+	}
+	return fieldSize + 1 + index
+}
+
+func updateRatings(ratings map[string]float64, names []string, ranks []int) {
+	if len(names) < 2 {
+		return
+	}
+	deltas := make([]float64, len(names))
+	factor := ratingFactor / float64(len(names) - 1)
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			score := 0.5
+			if ranks[i] < ranks[j] {
+				score = 1.0
+			} else if ranks[i] > ranks[j] {
+				score = 0.0
+			}
+			expected := getEloExpectation(getRating(ratings, names[i]), getRating(ratings, names[j]))
+			delta := factor * (score - expected)
+			deltas[i] += delta
+			deltas[j] -= delta
+		}
+	}
+	for i, name := range names {
+		ratings[name] = getRating(ratings, name) + deltas[i]
+	}
+}
+
+func applyRatings(drivers []driverSeasonalData, ratings ratingSystem) {
+	for i := range drivers {
+		driver := &drivers[i]
+		for j := range driver.races {
+			race := &driver.races[j]
+			snapshot, exists := ratings.getSnapshot(race.season, race.id)
+			if !exists {
+				continue
+			}
+			race.rating = getRating(snapshot.drivers, driver.name)
+			if race.constructor != "" {
+				race.constructorRating = getRating(snapshot.constructors, race.constructor)
+			}
+		}
+	}
+}
+
+func (r *ratingSystem) getSnapshot(season int, id int) (ratingSnapshot, bool) {
+	for _, snapshot := range r.history {
+		if snapshot.season == season && snapshot.id == id {
+			return snapshot, true
+		}
+	}
+	return ratingSnapshot{}, false
+}
+
+func (r *ratingSystem) getWinProbabilities(field []string) map[string]float64 {
+	probabilities := map[string]float64{}
+	total := 0.0
+	for _, driver := range field {
+		strength := getEloStrength(getRating(r.drivers, driver))
+		probabilities[driver] = strength
+		total += strength
+	}
+	for driver := range probabilities {
+		probabilities[driver] /= total
+	}
+	return probabilities
+}
+
+func getRating(ratings map[string]float64, name string) float64 {
+	rating, exists := ratings[name]
+	if !exists {
+		return eloInitialRating
+	}
+	return rating
+}
+
+func getSortedRatings(ratings map[string]float64, names []string) []ratingEntry {
+	entries := []ratingEntry{}
+	for name, rating := range ratings {
+		if names != nil && !slices.Contains(names, name) {
+			continue
+		}
+		entry := ratingEntry{
+			name: name,
+			rating: rating,
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func (a, b ratingEntry) int {
+		return cmp.Compare(b.rating, a.rating)
+	})
+	return entries
+}
\ No newline at end of file
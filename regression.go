@@ -3,14 +3,12 @@ package main
 import (
 	"cmp"
 	"fmt"
-	"io"
 	"log"
 	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/antchfx/htmlquery"
-	"github.com/cdipaolo/goml/linear"
 	"github.com/encratite/commons"
 )
 
@@ -29,8 +27,11 @@ const (
 	predictionsSeason = 2024
 	predictionsId = raceWindowSize + 1
 	enableMultiSeason = false
+	modePair = "pair"
+	modeDriver = "driver"
 )
 
+
 type raceResult int
 
 const (
@@ -56,6 +57,14 @@ type driverRaceResult struct {
 	result raceResult
 	position int
 	pole bool
+	constructor string
+	qualifying int
+	grid int
+	teammate string
+	teammateResult raceResult
+	teammatePosition int
+	rating float64
+	constructorRating float64
 }
 
 type featureMetaData struct {
@@ -65,20 +74,90 @@ type featureMetaData struct {
 	id int
 }
 
+type regressionOptions struct {
+	predictions bool
+	featureString string
+	mode string
+	validation string
+	folds int
+	backend string
+	train bool
+	modelPath string
+	parameters hyperparameters
+}
+
+type regressionEvaluation struct {
+	set featureSet
+	metrics classificationMetrics
+}
+
+type pairPrediction struct {
+	features []float64
+	metaData featureMetaData
+	probability float64
+}
+
 type driverPredictionData struct {
 	features []float64
 	label float64
 	metaData featureMetaData
 }
 
-func performRegression(predictions bool) {
-	paths := downloadFiles()
-	drivers := parseFiles(paths)
-	features, labels, metaData := getFeatures(drivers)
-	if !predictions {
-		fitAndEvaluate(features, labels)
-	} else {
-		makePredictions(features, labels, metaData, drivers)
+func performRegression(options regressionOptions) {
+	drivers := loadRegressionData()
+	switch options.mode {
+	case modePair:
+		performPairRegression(options, drivers)
+	case modeDriver:
+		if options.train || options.modelPath != "" {
+			log.Fatal("Model files are only supported in pair mode")
+		}
+		performFieldRegression(options.predictions, drivers)
+	default:
+		log.Fatalf("Unknown regression mode: %s", options.mode)
+	}
+}
+
+func loadRegressionData() []driverSeasonalData {
+	drivers, classifications := loadClassifications()
+	ratings := computeRatings(classifications)
+	applyRatings(drivers, ratings)
+	return drivers
+}
+
+func loadClassifications() ([]driverSeasonalData, []raceClassification) {
+	drivers, _, classifications := loadResults()
+	applyClassifications(drivers, classifications)
+	return drivers, classifications
+}
+
+func performPairRegression(options regressionOptions, drivers []driverSeasonalData) {
+	if options.train {
+		trainModel(options, drivers)
+		return
+	} else if options.predictions && options.modelPath != "" {
+		predictFromModel(options.modelPath, drivers)
+		return
+	}
+	sets := getFeatureSets(options.featureString)
+	parameters := getDefaultHyperparameters(options.backend)
+	evaluations := []regressionEvaluation{}
+	for _, set := range sets {
+		fmt.Printf("\nFeature set \"%s\": %s\n", set.name, strings.Join(set.featureNames, ", "))
+		if options.predictions {
+			features, labels, metaData := getFeatures(drivers, set, parameters.raceWindowSize)
+			makePredictions(set, features, labels, metaData, drivers, parameters)
+		} else if options.validation != "" {
+			evaluation := searchHyperparameters(set, drivers, options)
+			evaluations = append(evaluations, evaluation)
+		} else {
+			features, labels, metaData := getFeatures(drivers, set, parameters.raceWindowSize)
+			evaluation := fitAndEvaluate(set, features, labels, metaData, parameters)
+			evaluations = append(evaluations, evaluation)
+		}
+	}
+	if len(evaluations) > 1 {
+		printEvaluations(evaluations)
 	}
 }
 
@@ -106,26 +185,22 @@ func downloadFiles() []wikiDataPath {
 	return paths
 }
 
-func parseFiles(paths []wikiDataPath) []driverSeasonalData {
-	drivers := []driverSeasonalData{}
-	for _, path := range paths {
-		seasonDrivers := parseFile(path)
-		for _, driver := range seasonDrivers {
-			i := slices.IndexFunc(drivers, func (d driverSeasonalData) bool {
-				return d.name == driver.name
-			})
-			if i >= 0 {
-				races := &drivers[i].races
-				*races = append(*races, driver.races...)
-			} else {
-				drivers = append(drivers, driver)
-			}
+func mergeDrivers(drivers []driverSeasonalData, seasonDrivers []driverSeasonalData) []driverSeasonalData {
+	for _, driver := range seasonDrivers {
+		i := slices.IndexFunc(drivers, func (d driverSeasonalData) bool {
+			return d.name == driver.name
+		})
+		if i >= 0 {
+			races := &drivers[i].races
+			*races = append(*races, driver.races...)
+		} else {
+			drivers = append(drivers, driver)
 		}
 	}
 	return drivers
 }
 
-func parseFile(dataPath wikiDataPath) []driverSeasonalData {
+func parseFile(dataPath wikiDataPath) ([]driverSeasonalData, []wikiEvent) {
 	path := dataPath.path
 	fmt.Printf("Processing %s\n", path)
 	html := commons.ReadFile(path)
@@ -147,10 +222,19 @@ func parseFile(dataPath wikiDataPath) []driverSeasonalData {
 	if len(links) < 10 {
 		log.Fatalf("Failed to extract event codes from first row in %s", path)
 	}
-	eventCodes := []string{}
-	for _, link := range links {
-		eventCode := htmlquery.InnerText(link)
-		eventCodes = append(eventCodes, eventCode)
+	events := []wikiEvent{}
+	for i, link := range links {
+		id := i + 1
+		if dataPath.season == lastSeason && id > lastEventID {
+			break
+		}
+		event := wikiEvent{
+			season: dataPath.season,
+			id: id,
+			code: htmlquery.InnerText(link),
+			url: htmlquery.SelectAttr(link, "href"),
+		}
+		events = append(events, event)
 	}
 	drivers := []driverSeasonalData{}
 	for i := range driverLimit {
@@ -174,35 +258,14 @@ func parseFile(dataPath wikiDataPath) []driverSeasonalData {
 			firstText := htmlquery.FindOne(cell, "./text()[1]")
 			resultText := htmlquery.InnerText(firstText)
 			resultText = commons.Trim(resultText)
-			resultText = strings.Replace(resultText, "†", "", 1)
 			poleNode := htmlquery.FindOne(cell, ".//sup[text() = 'P']")
-			position, err := commons.ParseInt(resultText)
-			pole := poleNode != nil
-			var driverResult driverRaceResult
-			if err == nil {
-				driverResult = driverRaceResult{
-					season: dataPath.season,
-					id: id,
-					result: resultPosition, 
-					position: position,
-				}
-			} else {
-				var result raceResult
-				switch resultText {
-				case "Ret":
-					result = resultRetired
-				case "DSQ":
-					result = resultDisqualified
-				default:
-					result = resultOther
-				}
-				driverResult = driverRaceResult{
-					season: dataPath.season,
-					id: id,
-					result: result, 
-					position: 0,
-					pole: pole,
-				}
+			result, position := parseResultText(resultText)
+			driverResult := driverRaceResult{
+				season: dataPath.season,
+				id: id,
+				result: result,
+				position: position,
+				pole: poleNode != nil,
 			}
 			races = append(races, driverResult)
 		}
@@ -212,10 +275,10 @@ func parseFile(dataPath wikiDataPath) []driverSeasonalData {
 		}
 		drivers = append(drivers, driver)
 	}
-	return drivers
+	return drivers, events
 }
 
-func getFeatures(drivers []driverSeasonalData) ([][]float64, []float64, []featureMetaData) {
+func getFeatures(drivers []driverSeasonalData, set featureSet, windowSize int) ([][]float64, []float64, []featureMetaData) {
 	features := [][]float64{}
 	labels := []float64{}
 	metaData := []featureMetaData{}
@@ -225,14 +288,14 @@ func getFeatures(drivers []driverSeasonalData) ([][]float64, []float64, []featur
 				continue
 			}
 			for k, race1 := range driver1.races {
-				if k < raceWindowSize {
+				if k < windowSize {
 					continue
 				}
 				race2, exists := getMatchingRace(driver2, race1)
 				if !exists {
 					continue
 				}
-				raceFeatures := getRaceFeatures(driver1, driver2, k)
+				raceFeatures := getRaceFeatures(set, driver1, driver2, k, windowSize)
 				if raceFeatures == nil {
 					continue
 				}
@@ -255,106 +318,6 @@ func getFeatures(drivers []driverSeasonalData) ([][]float64, []float64, []featur
 	return features, labels, metaData
 }
 
-func getRaceFeatures(
-	driver1 driverSeasonalData,
-	driver2 driverSeasonalData,
-	k int,
-) []float64 {
-	return getSimpleFeatures(driver1, driver2, k)
-	// return getComboFeatures(driver1, driver2, k)
-}
-
-func getSimpleFeatures(
-	driver1 driverSeasonalData,
-	driver2 driverSeasonalData,
-	k int,
-) []float64 {
-	firstRace := driver1.races[k - 1]
-	lastRace := driver1.races[k - raceWindowSize]
-	if !enableMultiSeason && firstRace.season != lastRace.season {
-		return nil
-	}
-	wins := 0
-	secondPlace := 0
-	wonLastRace := 0.0
-	for l := 1; l <= raceWindowSize; l++ {
-		windowRace1 := driver1.races[k - l]
-		windowRace2, exists := getMatchingRace(driver2, windowRace1)
-		if !exists {
-			return nil
-		}
-		if windowRace1.isWin() || windowRace2.isWin() {
-			if l == 1 {
-				wonLastRace = 1.0
-			} else {
-				wins++
-			}
-		}
-		if windowRace1.isPosition(2) || windowRace2.isPosition(2) {
-			secondPlace++
-		}
-	}
-	features := []float64{
-		float64(wins),
-		float64(secondPlace),
-		wonLastRace,
-	}
-	return features
-}
-
-func getComboFeatures(
-	driver1 driverSeasonalData,
-	driver2 driverSeasonalData,
-	k int,
-) []float64 {
-	position12 := 0
-	position10 := 0
-	position20 := 0
-	retired := 0
-	wonLastRace := 0.0
-	firstRace := driver1.races[k - 1]
-	lastRace := driver1.races[k - raceWindowSize]
-	if !enableMultiSeason && firstRace.season != lastRace.season {
-		return nil
-	}
-	for l := 1; l <= raceWindowSize; l++ {
-		windowRace1 := driver1.races[k - l]
-		windowRace2, exists := getMatchingRace(driver2, windowRace1)
-		if !exists {
-			return nil
-		}
-		r1p1 := windowRace1.isPosition(1)
-		r1p2 := windowRace1.isPosition(2)
-		r1p0 := !r1p1 && !r1p2
-		r2p1 := windowRace2.isPosition(1)
-		r2p2 := windowRace2.isPosition(2)
-		r2p0 := !r2p1 && !r2p2
-		if (r1p1 && r2p2) || (r2p1 && r1p2) {
-			position12++
-		} else if (r1p1 && r2p0) || (r2p1 && r1p0) {
-			position10++
-		} else if (r1p2 && r2p0) || (r2p2 && r1p0) {
-			position20++
-		}
-		if windowRace1.result == resultRetired || windowRace2.result == resultRetired {
-			retired++
-		}
-		if windowRace1.isWin() || windowRace2.isWin() {
-			wonLastRace = 1.0
-		} else {
-			wonLastRace = 0.0
-		}
-	}
-	features := []float64{
-		float64(position12),
-		float64(position10),
-		float64(position20),
-		float64(retired),
-		float64(wonLastRace),
-	}
-	return features
-}
-
 func getMatchingRace(driver2 driverSeasonalData, race driverRaceResult) (driverRaceResult, bool) {
 	matchingRace, exists := commons.Find(driver2.races, func (r driverRaceResult) bool {
 		return r.season == race.season && r.id == race.id
@@ -362,58 +325,55 @@ func getMatchingRace(driver2 driverSeasonalData, race driverRaceResult) (driverR
 	return matchingRace, exists
 }
 
-func fitAndEvaluate(features [][]float64, labels []float64) {
-	model := linear.NewLogistic(logisticMethod, alpha, regularization, maxIterations, features, labels)
-	err := model.Learn()
-	if err != nil {
-		log.Fatalf("Failed to train model: %v", err)
-	}
-	truePositives := 0
-	falsePositives := 0
-	trueNegatives := 0
-	falseNegatives := 0
-	positiveLabels := 0
+func fitAndEvaluate(
+	set featureSet,
+	features [][]float64,
+	labels []float64,
+	metaData []featureMetaData,
+	parameters hyperparameters,
+) regressionEvaluation {
+	model := newModel(parameters)
+	model.fit(features, labels, metaData)
+	probabilities := []float64{}
 	for i, currentFeatures := range features {
-		label := labels[i] == 1.0
-		predictionVector, err := model.Predict(currentFeatures)
-		if err != nil {
-			log.Fatalf("Failed to make predictions: %v", err)
-		}
-		prediction := predictionVector[0] > classThreshold
-		if label {
-			if prediction {
-				truePositives++
-			} else {
-				falseNegatives++
-			}
-			positiveLabels++
-		} else {
-			if prediction {
-				falsePositives++
-			} else {
-				trueNegatives++
-			}
-		}
+		probability := model.predict(currentFeatures, metaData[i])
+		probabilities = append(probabilities, probability)
 	}
-	total := len(features)
-	printRatio := func (description string, count int) {
-		percentage := 100.0 * float64(count) / float64(total)
-		fmt.Printf("%s: %.1f%% (%d samples)\n", description, percentage, count)
+	metrics := getClassificationMetrics(probabilities, labels, parameters.classThreshold)
+	metrics.print()
+	evaluation := regressionEvaluation{
+		set: set,
+		metrics: metrics,
 	}
-	positiveTerm := float64(truePositives) / (float64(truePositives) + float64(falseNegatives))
-	negativeTerm := float64(trueNegatives) / (float64(trueNegatives) + float64(falsePositives))
-	youdensJ := positiveTerm + negativeTerm - 1.0
-	f1Score := 2.0 * float64(truePositives) / (2.0 * float64(truePositives) + float64(falsePositives) + float64(falseNegatives))
-	printRatio("True positives", truePositives)
-	printRatio("True negatives", trueNegatives)
-	printRatio("False positives", falsePositives)
-	printRatio("False negatives", falseNegatives)
-	printRatio("True labels", positiveLabels)
-	fmt.Printf("Youden's J: %.3f\n", youdensJ)
-	fmt.Printf("F1 score: %.3f\n", f1Score)
+	return evaluation
 }
 
-func makePredictions(features [][]float64, labels []float64, metaData []featureMetaData, drivers []driverSeasonalData) {
+func printEvaluations(evaluations []regressionEvaluation) {
+	fmt.Printf("\nComparison of feature sets:\n")
+	fmt.Printf("\t%-10s %8s %11s %9s %8s %9s %8s\n", "Set", "Samples", "Youden's J", "F1 score", "ROC AUC", "Log loss", "Brier")
+	for _, evaluation := range evaluations {
+		metrics := evaluation.metrics
+		fmt.Printf(
+			"\t%-10s %8d %11.3f %9.3f %8.3f %9.3f %8.3f\n",
+			evaluation.set.name,
+			metrics.samples,
+			metrics.youdensJ,
+			metrics.f1Score,
+			metrics.rocAUC,
+			metrics.logLoss,
+			metrics.brierScore,
+		)
+	}
+}
+
+func makePredictions(
+	set featureSet,
+	features [][]float64,
+	labels []float64,
+	metaData []featureMetaData,
+	drivers []driverSeasonalData,
+	parameters hyperparameters,
+) {
 	predictionData := []driverPredictionData{}
 	for i, currentFeatures := range features {
 		label := labels[i]
@@ -433,7 +393,7 @@ func makePredictions(features [][]float64, labels []float64, metaData []featureM
 		}
 		return cmp.Compare(meta1.id, meta2.id)
 	})
-	var model *linear.Logistic
+	var model Model
 	for id := predictionsId; true; id++ {
 		i := slices.IndexFunc(predictionData, func (f driverPredictionData) bool {
 			return f.metaData.season == predictionsSeason && f.metaData.id == id
@@ -441,35 +401,33 @@ func makePredictions(features [][]float64, labels []float64, metaData []featureM
 		if i == -1 {
 			break
 		}
-		trainingFeatures := [][]float64{}
-		trainingLabels := []float64{}
-		for _, currentPredictionData := range predictionData[:i] {
-			trainingFeatures = append(trainingFeatures, currentPredictionData.features)
-			trainingLabels = append(trainingLabels, currentPredictionData.label)
-		}
-		model = linear.NewLogistic(logisticMethod, alpha, regularization, maxIterations, trainingFeatures, trainingLabels)
-		model.Output = io.Discard
-		err := model.Learn()
-		if err != nil {
-			log.Fatalf("Failed to train model: %v", err)
-		}
+		model = fitPredictionData(predictionData[:i], parameters)
 		for j := i; j < len(predictionData); j++ {
 			currentPredictionData := predictionData[j]
 			currentMetaData := currentPredictionData.metaData
 			if currentMetaData.season != predictionsSeason || currentMetaData.id != id {
 				break
 			}
-			printPrediction(
-				currentMetaData.season,
-				currentMetaData.id,
-				currentMetaData.driver1,
-				currentMetaData.driver2,
-				currentPredictionData.features,
-				model,
-			)
+			printPrediction(currentPredictionData.features, currentMetaData, model)
 		}
 	}
+	model = fitPredictionData(predictionData, parameters)
+	printUpcomingPredictions(set, drivers, model, parameters.raceWindowSize)
+}
+
+func printUpcomingPredictions(set featureSet, drivers []driverSeasonalData, model Model, windowSize int) {
 	fmt.Printf("\nPrediction for upcoming race:\n")
+	for _, prediction := range getUpcomingPredictions(set, drivers, model, windowSize) {
+		printPrediction(prediction.features, prediction.metaData, model)
+	}
+}
+
+func getUpcomingPredictions(set featureSet, drivers []driverSeasonalData, model Model, windowSize int) []pairPrediction {
+	predictions := []pairPrediction{}
+	if set.usesRaceGrid {
+		log.Printf("Feature set %s requires the starting grid, which is not known for the upcoming race", set.name)
+		return predictions
+	}
 	for i, driver1 := range drivers {
 		for j, driver2 := range drivers {
 			if i >= j {
@@ -483,38 +441,46 @@ func makePredictions(features [][]float64, labels []float64, metaData []featureM
 				if !exists {
 					continue
 				}
-				raceFeatures := getRaceFeatures(driver1, driver2, k + 1)
+				raceFeatures := getRaceFeatures(set, driver1, driver2, k + 1, windowSize)
 				if raceFeatures == nil {
 					continue
 				}
-				printPrediction(
-					lastSeason,
-					lastEventID + 1,
-					driver1.name,
-					driver2.name,
-					raceFeatures,
-					model,
-				)
+				upcomingMetaData := featureMetaData{
+					driver1: driver1.name,
+					driver2: driver2.name,
+					season: lastSeason,
+					id: lastEventID + 1,
+				}
+				prediction := pairPrediction{
+					features: raceFeatures,
+					metaData: upcomingMetaData,
+					probability: model.predict(raceFeatures, upcomingMetaData),
+				}
+				predictions = append(predictions, prediction)
 			}
 		}
 	}
+	return predictions
 }
 
-func printPrediction(
-	season int,
-	eventID int,
-	driver1 string,
-	driver2 string,
-	features []float64,
-	model *linear.Logistic,
-) {
-	predictionVector, err := model.Predict(features)
-	if err != nil {
-		log.Fatalf("Failed to make prediction: %v", err)
+func fitPredictionData(predictionData []driverPredictionData, parameters hyperparameters) Model {
+	trainingFeatures := [][]float64{}
+	trainingLabels := []float64{}
+	trainingMetaData := []featureMetaData{}
+	for _, currentPredictionData := range predictionData {
+		trainingFeatures = append(trainingFeatures, currentPredictionData.features)
+		trainingLabels = append(trainingLabels, currentPredictionData.label)
+		trainingMetaData = append(trainingMetaData, currentPredictionData.metaData)
 	}
-	prediction := predictionVector[0]
+	model := newModel(parameters)
+	model.fit(trainingFeatures, trainingLabels, trainingMetaData)
+	return model
+}
+
+func printPrediction(features []float64, metaData featureMetaData, model Model) {
+	prediction := model.predict(features, metaData)
 	format := "Season = %d, event ID = %d, driver 1 = %s, driver 2 = %s: %.3f\n"
-	fmt.Printf(format, season, eventID, driver1, driver2, prediction)
+	fmt.Printf(format, metaData.season, metaData.id, metaData.driver1, metaData.driver2, prediction)
 }
 
 func (r *driverRaceResult) isWin() bool {
@@ -523,4 +489,19 @@ func (r *driverRaceResult) isWin() bool {
 
 func (r *driverRaceResult) isPosition(position int) bool {
 	return r.result == resultPosition && r.position == position
+}
+
+func (r *driverRaceResult) isPodium() bool {
+	return r.result == resultPosition && r.position <= 3
+}
+
+func (r *driverRaceResult) isTeammatePodium() bool {
+	return r.teammateResult == resultPosition && r.teammatePosition <= 3
+}
+
+func (r *driverRaceResult) beatTeammate() bool {
+	if r.result != resultPosition {
+		return false
+	}
+	return r.teammateResult != resultPosition || r.position < r.teammatePosition
 }
\ No newline at end of file
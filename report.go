@@ -0,0 +1,194 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	reportFinal = "final"
+	reportPriceWidth = 13
+	reportChangeWidth = 7
+)
+
+type reportOptions struct {
+	drivers []string
+	family string
+	venue string
+	from string
+	to string
+	season int
+	columns string
+	winners bool
+}
+
+type reportColumn struct {
+	name string
+	getPrice func (d driverData) float64
+}
+
+func printPriceReport(options reportOptions) {
+	loadConfiguration()
+	family := getMarketFamily(options.family)
+	venue := getVenue(options.venue)
+	columns := getReportColumns(options.columns)
+	raceConfigs := getReportRaces(options.from, options.to, options.season)
+	db := openCache()
+	defer closeStore(db)
+	for _, raceConfig := range raceConfigs {
+		race := loadRace(db, raceConfig)
+		race = race.filter(family.name, venue.Name)
+		if len(race.drivers) == 0 {
+			continue
+		}
+		printRaceReport(race, columns, options)
+	}
+}
+
+func getReportColumns(columnString string) []reportColumn {
+	columns := []reportColumn{}
+	for _, name := range strings.Split(columnString, ",") {
+		name = strings.TrimSpace(name)
+		var column reportColumn
+		if name == reportFinal {
+			column = reportColumn{
+				name: "Final",
+				getPrice: func (d driverData) float64 {
+					return d.finalPrice
+				},
+			}
+		} else {
+			stratType := getStrategyType(name)
+			column = reportColumn{
+				name: strings.ToUpper(name[:1]) + name[1:],
+				getPrice: func (d driverData) float64 {
+					return d.getPrice(stratType)
+				},
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func getReportRaces(from string, to string, season int) []RaceConfiguration {
+	start := 0
+	end := len(configuration.Races) - 1
+	if from != "" {
+		start = getRaceIndex(from)
+	}
+	if to != "" {
+		end = getRaceIndex(to)
+	}
+	if start > end {
+		log.Fatalf("Race %s comes after %s in the configuration", from, to)
+	}
+	raceConfigs := []RaceConfiguration{}
+	for _, raceConfig := range configuration.Races[start:end + 1] {
+		if season == 0 || raceConfig.Season == season {
+			raceConfigs = append(raceConfigs, raceConfig)
+		}
+	}
+	return raceConfigs
+}
+
+func printRaceReport(race raceData, columns []reportColumn, options reportOptions) {
+	ranks := []map[string]int{}
+	for _, column := range columns {
+		ranks = append(ranks, getReportRanks(race.drivers, column))
+	}
+	drivers := slices.Clone(race.drivers)
+	slices.SortFunc(drivers, func (a, b driverData) int {
+		return cmp.Or(cmp.Compare(columns[0].getPrice(b), columns[0].getPrice(a)), cmp.Compare(a.name, b.name))
+	})
+	drivers = slices.DeleteFunc(drivers, func (d driverData) bool {
+		return !matchesDriverNames(d.name, options.drivers)
+	})
+	nameWidth := len("Driver")
+	for _, driver := range drivers {
+		nameWidth = max(nameWidth, len(driver.name))
+	}
+	fmt.Printf("%s:\n", race.name)
+	header := fmt.Sprintf("\t%-*s", nameWidth, "Driver")
+	for i, column := range columns {
+		header += fmt.Sprintf("  %-*s", reportPriceWidth, column.name)
+		if i > 0 {
+			header += fmt.Sprintf("  %*s", reportChangeWidth, "Change")
+		}
+	}
+	if options.winners {
+		header += "  Winner"
+	}
+	fmt.Println(header)
+	for _, driver := range drivers {
+		line := fmt.Sprintf("\t%-*s", nameWidth, driver.name)
+		for i, column := range columns {
+			price := column.getPrice(driver)
+			line += fmt.Sprintf("  %-*s", reportPriceWidth, fmt.Sprintf("%.3f (#%d)", price, ranks[i][driver.name]))
+			if i > 0 {
+				change := price - columns[i - 1].getPrice(driver)
+				line += fmt.Sprintf("  %+*.3f", reportChangeWidth, change)
+			}
+		}
+		if options.winners && driver.winner {
+			line += "  Yes"
+		}
+		fmt.Println(line)
+	}
+	if options.winners {
+		for _, driver := range race.drivers {
+			if driver.winner && !matchesDriverNames(driver.name, options.drivers) {
+				fmt.Printf("\tWinner: %s\n", driver.name)
+			}
+		}
+	}
+}
+
+func getReportRanks(drivers []driverData, column reportColumn) map[string]int {
+	ranked := slices.Clone(drivers)
+	slices.SortFunc(ranked, func (a, b driverData) int {
+		return cmp.Or(cmp.Compare(column.getPrice(b), column.getPrice(a)), cmp.Compare(a.name, b.name))
+	})
+	ranks := map[string]int{}
+	for i, driver := range ranked {
+		ranks[driver.name] = i + 1
+	}
+	return ranks
+}
+
+func matchesDriverNames(name string, driverNames []string) bool {
+	if len(driverNames) == 0 {
+		return true
+	}
+	for _, driverName := range driverNames {
+		if strings.Contains(name, getSlug(driverName)) {
+			return true
+		}
+	}
+	return false
+}
+
+func printWinners() {
+	loadConfiguration()
+	races := loadRaces()
+	races = filterRaces(races, familyWin, getVenue("").Name)
+	for _, race := range races {
+		winner := getWinner(race)
+		fmt.Printf("%s: %s\n", race.name, winner.name)
+	}
+}
+
+func getWinner(race raceData) driverData {
+	winner, exists := commons.Find(race.drivers, func (d driverData) bool {
+		return d.winner
+	})
+	if !exists {
+		log.Fatalf("Unable to determine winner of %s", race.name)
+	}
+	return winner
+}
\ No newline at end of file
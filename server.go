@@ -0,0 +1,359 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+type analysisServer struct {
+	races []raceData
+	series map[string][]seriesResponse
+	predictions []predictionResponse
+}
+
+type raceResponse struct {
+	Name string `json:"name"`
+	Metadata RaceMetadata `json:"metadata"`
+	Practice time.Time `json:"practice"`
+	Qualifying time.Time `json:"qualifying"`
+	Race time.Time `json:"race"`
+	Venues []string `json:"venues"`
+	Markets int `json:"markets"`
+}
+
+type seriesResponse struct {
+	Market string `json:"market"`
+	Venue string `json:"venue"`
+	Family string `json:"family"`
+	Winner bool `json:"winner"`
+	Void bool `json:"void"`
+	Points []seriesPointResponse `json:"points"`
+}
+
+type seriesPointResponse struct {
+	Timestamp time.Time `json:"timestamp"`
+	Price float64 `json:"price"`
+	Bid float64 `json:"bid,omitempty"`
+	Ask float64 `json:"ask,omitempty"`
+}
+
+type outcomeGroupResponse struct {
+	Session string `json:"session"`
+	Bins []outcomeBinResponse `json:"bins"`
+}
+
+type outcomeBinResponse struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Samples int `json:"samples"`
+	Hits int `json:"hits"`
+	MeanPrice float64 `json:"meanPrice"`
+	HitRate float64 `json:"hitRate"`
+}
+
+type backtestResponse struct {
+	Session string `json:"session"`
+	Family string `json:"family"`
+	Venue string `json:"venue"`
+	Bets []betResponse `json:"bets"`
+	Races []string `json:"races"`
+	Returns []float64 `json:"returns"`
+	Total float64 `json:"total"`
+	RiskAdjusted float64 `json:"riskAdjusted"`
+	Snapshot string `json:"snapshot"`
+	Skipped int `json:"skipped"`
+}
+
+type betResponse struct {
+	Position int `json:"position"`
+	Yes bool `json:"yes"`
+}
+
+type predictionResponse struct {
+	Driver1 string `json:"driver1"`
+	Driver2 string `json:"driver2"`
+	Season int `json:"season"`
+	ID int `json:"id"`
+	Probability float64 `json:"probability"`
+}
+
+func runServer(address string, modelPath string, featureString string, backend string) {
+	loadConfiguration()
+	races := loadRaces()
+	server := &analysisServer{
+		races: races,
+		series: loadSeriesResponses(races),
+		predictions: loadPredictions(modelPath, featureString, backend),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", server.handleDashboard)
+	mux.HandleFunc("GET /api/races", server.handleRaces)
+	mux.HandleFunc("GET /api/series", server.handleSeries)
+	mux.HandleFunc("GET /api/outcomes", server.handleOutcomes)
+	mux.HandleFunc("GET /api/backtest", server.handleBacktest)
+	mux.HandleFunc("GET /api/predictions", server.handlePredictions)
+	fmt.Printf("Serving %d races on http://%s\n", len(server.races), address)
+	err := http.ListenAndServe(address, mux)
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+func (s *analysisServer) handleDashboard(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.Write(dashboardHTML)
+}
+
+func (s *analysisServer) handleRaces(writer http.ResponseWriter, _ *http.Request) {
+	races := []raceResponse{}
+	for i, raceConfig := range configuration.Races {
+		venues := []string{}
+		for _, venue := range raceConfig.getVenues() {
+			venues = append(venues, venue.Name)
+		}
+		race := raceResponse{
+			Name: raceConfig.Path,
+			Metadata: raceConfig.RaceMetadata,
+			Practice: raceConfig.Practice.Time,
+			Qualifying: raceConfig.Qualifying.Time,
+			Race: raceConfig.Race.Time,
+			Venues: venues,
+			Markets: len(s.races[i].drivers),
+		}
+		races = append(races, race)
+	}
+	writeJSON(writer, races)
+}
+
+func (s *analysisServer) handleSeries(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	racePath := query.Get("race")
+	i := slices.IndexFunc(configuration.Races, func (r RaceConfiguration) bool {
+		return r.Path == racePath
+	})
+	if i == -1 {
+		http.Error(writer, "Unknown race", http.StatusNotFound)
+		return
+	}
+	family, familyValid := getQueryFamily(request)
+	venue, venueValid := getQueryVenue(request)
+	if !familyValid || !venueValid {
+		http.Error(writer, "Unknown family or venue", http.StatusBadRequest)
+		return
+	}
+	response, exists := s.series[getSeriesKey(racePath, venue.Name, family)]
+	if !exists {
+		response = []seriesResponse{}
+	}
+	writeJSON(writer, response)
+}
+
+func loadSeriesResponses(races []raceData) map[string][]seriesResponse {
+	db := openCache()
+	defer closeStore(db)
+	responses := map[string][]seriesResponse{}
+	for i, raceConfig := range configuration.Races {
+		for _, venue := range raceConfig.getVenues() {
+			for _, driver := range races[i].drivers {
+				if driver.venue != venue.Name || driver.void {
+					continue
+				}
+				series := getMarketSeries(db, raceConfig, venue, driver)
+				points := []seriesPointResponse{}
+				for _, point := range series {
+					points = append(points, seriesPointResponse{
+						Timestamp: point.timestamp,
+						Price: point.price,
+						Bid: point.quote.bid,
+						Ask: point.quote.ask,
+					})
+				}
+				key := getSeriesKey(raceConfig.Path, venue.Name, driver.family)
+				responses[key] = append(responses[key], seriesResponse{
+					Market: driver.name,
+					Venue: driver.venue,
+					Family: driver.family,
+					Winner: driver.winner,
+					Void: driver.void,
+					Points: points,
+				})
+			}
+		}
+	}
+	return responses
+}
+
+func getMarketSeries(db *bolt.DB, raceConfig RaceConfiguration, venue VenueConfiguration, driver driverData) []pricePoint {
+	for _, path := range getMarketPaths(raceConfig, venue) {
+		fileName := filepath.Base(path)
+		family, name, exists := venue.matchMarket(fileName)
+		if !exists || family.name != driver.family || name != driver.name {
+			continue
+		}
+		series := loadSeries(db, getSeriesKey(raceConfig.Path, venue.Name, fileName))
+		if series == nil {
+			series = readPriceSeries(path, venue)
+		}
+		return series
+	}
+	return []pricePoint{}
+}
+
+func (s *analysisServer) handleOutcomes(writer http.ResponseWriter, request *http.Request) {
+	family, familyValid := getQueryFamily(request)
+	venue, venueValid := getQueryVenue(request)
+	if !familyValid || !venueValid {
+		http.Error(writer, "Unknown family or venue", http.StatusBadRequest)
+		return
+	}
+	races := filterRaces(s.races, family, venue.Name)
+	response := []outcomeGroupResponse{}
+	for _, group := range getOutcomeGroups(races) {
+		groupResponse := outcomeGroupResponse{
+			Session: group.name,
+			Bins: []outcomeBinResponse{},
+		}
+		for _, bin := range group.bins {
+			binResponse := outcomeBinResponse{
+				Min: bin.priceMin,
+				Max: bin.priceMax,
+				Samples: len(bin.prices),
+				Hits: bin.hits,
+			}
+			if len(bin.prices) > 0 {
+				for _, price := range bin.prices {
+					binResponse.MeanPrice += price
+				}
+				binResponse.MeanPrice /= float64(len(bin.prices))
+				binResponse.HitRate = float64(bin.hits) / float64(len(bin.prices))
+			}
+			groupResponse.Bins = append(groupResponse.Bins, binResponse)
+		}
+		response = append(response, groupResponse)
+	}
+	writeJSON(writer, response)
+}
+
+func (s *analysisServer) handleBacktest(writer http.ResponseWriter, request *http.Request) {
+	family, familyValid := getQueryFamily(request)
+	venue, venueValid := getQueryVenue(request)
+	if !familyValid || !venueValid {
+		http.Error(writer, "Unknown family or venue", http.StatusBadRequest)
+		return
+	}
+	races := filterRaces(s.races, family, venue.Name)
+	raceNames := []string{}
+	for _, race := range races {
+		raceNames = append(raceNames, race.name)
+	}
+	response := []backtestResponse{}
+	for _, strategy := range getBacktestStrategies(family, venue) {
+		result := getBacktestResult(strategy, races)
+		bets := []betResponse{}
+		for _, bet := range strategy.bets {
+			bets = append(bets, betResponse{
+				Position: bet.position,
+				Yes: bet.yes,
+			})
+		}
+		response = append(response, backtestResponse{
+			Session: getStrategyTypeString(strategy.stratType),
+			Family: family,
+			Venue: venue.Name,
+			Bets: bets,
+			Races: raceNames,
+			Returns: result.returns,
+			Total: result.total,
+			RiskAdjusted: getFiniteValue(result.riskAdjusted),
+			Snapshot: configuration.Snapshot.describe(),
+			Skipped: result.skipped,
+		})
+	}
+	writeJSON(writer, response)
+}
+
+func (s *analysisServer) handlePredictions(writer http.ResponseWriter, _ *http.Request) {
+	writeJSON(writer, s.predictions)
+}
+
+func loadPredictions(modelPath string, featureString string, backend string) []predictionResponse {
+	drivers := loadRegressionData()
+	var set featureSet
+	var model Model
+	windowSize := raceWindowSize
+	if modelPath != "" {
+		var input modelFile
+		set, model, input = loadModel(modelPath)
+		windowSize = input.Hyperparameters.RaceWindowSize
+	} else {
+		set = getFeatureSets(featureString)[0]
+		parameters := getDefaultHyperparameters(backend)
+		features, labels, metaData := getFeatures(drivers, set, parameters.raceWindowSize)
+		model = newModel(parameters)
+		model.fit(features, labels, metaData)
+	}
+	predictions := []predictionResponse{}
+	for _, prediction := range getUpcomingPredictions(set, drivers, model, windowSize) {
+		predictions = append(predictions, predictionResponse{
+			Driver1: prediction.metaData.driver1,
+			Driver2: prediction.metaData.driver2,
+			Season: prediction.metaData.season,
+			ID: prediction.metaData.id,
+			Probability: getFiniteValue(prediction.probability),
+		})
+	}
+	return predictions
+}
+
+func getQueryFamily(request *http.Request) (string, bool) {
+	family := request.URL.Query().Get("family")
+	if family == "" {
+		return familyWin, true
+	}
+	exists := slices.ContainsFunc(marketFamilies, func (f marketFamily) bool {
+		return f.name == family
+	})
+	return family, exists
+}
+
+func getQueryVenue(request *http.Request) (VenueConfiguration, bool) {
+	name := request.URL.Query().Get("venue")
+	defaultVenue := getVenue("")
+	if name == "" || name == defaultVenue.Name {
+		return defaultVenue, true
+	}
+	i := slices.IndexFunc(configuration.Venues, func (v VenueConfiguration) bool {
+		return v.Name == name
+	})
+	if i == -1 {
+		return VenueConfiguration{}, false
+	}
+	return configuration.Venues[i], true
+}
+
+func getFiniteValue(value float64) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0.0
+	}
+	return value
+}
+
+func writeJSON(writer http.ResponseWriter, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(writer).Encode(value)
+	if err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
\ No newline at end of file
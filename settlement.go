@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/encratite/commons"
+	"golang.org/x/text/unicode/norm"
+	"gopkg.in/yaml.v3"
+)
+
+type RaceResults struct {
+	Winner string `yaml:"winner,omitempty"`
+	Podium []string `yaml:"podium,omitempty"`
+	Pole string `yaml:"pole,omitempty"`
+	FastestLap string `yaml:"fastestLap,omitempty"`
+	Constructor string `yaml:"constructor,omitempty"`
+	HeadToHead map[string]string `yaml:"headToHead,omitempty"`
+	Void []string `yaml:"void,omitempty"`
+}
+
+func loadSettlements(path string) map[string]RaceResults {
+	yamlData := commons.ReadFile(path)
+	settlements := map[string]RaceResults{}
+	err := yaml.Unmarshal(yamlData, &settlements)
+	if err != nil {
+		log.Fatalf("Failed to unmarshal settlements file %s: %v", path, err)
+	}
+	return settlements
+}
+
+func (r *RaceResults) getOutcome(family string, name string) (bool, bool, bool) {
+	for _, voidName := range r.Void {
+		if getSlug(voidName) == name {
+			return false, true, true
+		}
+	}
+	matches := func (declared string) bool {
+		return getSlug(declared) == name
+	}
+	switch family {
+	case familyWin:
+		if r.Winner != "" {
+			return matches(r.Winner), false, true
+		}
+	case familyPodium:
+		if len(r.Podium) > 0 {
+			return slices.ContainsFunc(r.Podium, matches), false, true
+		}
+	case familyPole:
+		if r.Pole != "" {
+			return matches(r.Pole), false, true
+		}
+	case familyFastestLap:
+		if r.FastestLap != "" {
+			return matches(r.FastestLap), false, true
+		}
+	case familyConstructor:
+		if r.Constructor != "" {
+			return matches(r.Constructor), false, true
+		}
+	case familyHeadToHead:
+		for market, winner := range r.HeadToHead {
+			if getSlug(market) == name {
+				return strings.HasPrefix(name, getSlug(winner) + "-vs-"), false, true
+			}
+		}
+	}
+	return false, false, false
+}
+
+func settleMarket(
+	family marketFamily,
+	name string,
+	finalPrice float64,
+	raceConfig RaceConfiguration,
+) (bool, bool) {
+	winner, void := family.settle(finalPrice)
+	resolved := finalPrice > winnerPriceLimit || finalPrice < 1.0 - winnerPriceLimit
+	if raceConfig.Results == nil {
+		if !resolved && !void {
+			log.Printf("Unresolved %s market for %s in %s with a final price of %.2f", family.name, name, raceConfig.Path, finalPrice)
+		}
+		return winner, void
+	}
+	declaredWinner, declaredVoid, declared := raceConfig.Results.getOutcome(family.name, name)
+	if !declared {
+		return winner, void
+	}
+	if resolved && (declaredWinner != winner || declaredVoid) {
+		format := "Settlement disagreement in %s market for %s in %s: inferred %s from final price %.2f, declared %s"
+		log.Printf(format, family.name, name, raceConfig.Path, getOutcomeString(winner, false), finalPrice, getOutcomeString(declaredWinner, declaredVoid))
+	}
+	return declaredWinner, declaredVoid
+}
+
+func getOutcomeString(winner bool, void bool) string {
+	if void {
+		return "void"
+	} else if winner {
+		return "yes"
+	}
+	return "no"
+}
+
+func printSettlements() {
+	loadConfiguration()
+	_, events, classifications := loadResults()
+	settlements := map[string]RaceResults{}
+	for _, raceConfig := range configuration.Races {
+		i := slices.IndexFunc(events, func (e wikiEvent) bool {
+			return isMatchingEvent(raceConfig, e)
+		})
+		if i == -1 {
+			log.Printf("Unable to find Wikipedia event for race %s", raceConfig.Path)
+			continue
+		}
+		event := events[i]
+		classification, exists := commons.Find(classifications, func (c raceClassification) bool {
+			return c.season == event.season && c.id == event.id
+		})
+		if !exists {
+			continue
+		}
+		results := RaceResults{
+			Podium: []string{},
+		}
+		for _, entry := range classification.entries {
+			if entry.result == resultPosition && entry.position == 1 {
+				results.Winner = getSlug(entry.driver)
+				results.Constructor = getSlug(entry.constructor)
+			}
+			if entry.result == resultPosition && entry.position <= podiumPositions {
+				results.Podium = append(results.Podium, getSlug(entry.driver))
+			}
+			if entry.qualifying == 1 {
+				results.Pole = getSlug(entry.driver)
+			}
+		}
+		settlements[raceConfig.Path] = results
+	}
+	yamlData, err := yaml.Marshal(settlements)
+	if err != nil {
+		log.Fatalf("Failed to marshal settlements: %v", err)
+	}
+	fmt.Printf("# Fastest lap results are not part of the Wikipedia classification, declare fastestLap manually\n")
+	fmt.Print(string(yamlData))
+}
+
+func isMatchingEvent(raceConfig RaceConfiguration, event wikiEvent) bool {
+	if raceConfig.Season != event.season {
+		return false
+	}
+	if raceConfig.Round > 0 {
+		return raceConfig.Round == event.id
+	}
+	title := event.url[strings.LastIndex(event.url, "/") + 1:]
+	title = strings.TrimPrefix(title, fmt.Sprintf("%d_", event.season))
+	title = strings.TrimSuffix(title, "_Grand_Prix")
+	return strings.Contains(getSlug(raceConfig.Path), getSlug(title))
+}
+
+func getSlug(name string) string {
+	builder := strings.Builder{}
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+		} else if builder.Len() > 0 && !strings.HasSuffix(builder.String(), "-") {
+			builder.WriteRune('-')
+		}
+	}
+	return strings.TrimSuffix(builder.String(), "-")
+}
\ No newline at end of file
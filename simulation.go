@@ -0,0 +1,255 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	simulationCount = 100000
+	simulationSeed = 1
+	defaultDNFProbability = 0.08
+	minDNFProbability = 0.02
+	minStrength = 1e-6
+	podiumPositions = 3
+	pointsPositions = 6
+	headToHeadLimit = 6
+	sourceRatings = "ratings"
+	sourceMarket = "market"
+	sourceModel = "model"
+)
+
+type driverStrength struct {
+	name string
+	strength float64
+	dnfProbability float64
+}
+
+type simulationResult struct {
+	drivers []string
+	simulations int
+	wins []int
+	podiums []int
+	top6 []int
+	dnfs []int
+	headToHead [][]int
+}
+
+func runSimulation(source string, racePath string, snapshot string) {
+	var strengths []driverStrength
+	switch source {
+	case sourceRatings:
+		strengths = getRatingStrengths()
+	case sourceMarket:
+		strengths = getMarketStrengths(racePath, snapshot)
+	case sourceModel:
+		strengths = getModelStrengths()
+	default:
+		log.Fatalf("Unknown simulation source: %s", source)
+	}
+	if len(strengths) < 2 {
+		log.Fatalf("Not enough drivers to simulate a race: %d", len(strengths))
+	}
+	for i := range strengths {
+		driver := &strengths[i]
+		if !(driver.strength >= minStrength) {
+			log.Printf("Clamping strength %g of %s to %g", driver.strength, driver.name, minStrength)
+			driver.strength = minStrength
+		}
+	}
+	random := rand.New(rand.NewPCG(simulationSeed, simulationSeed))
+	result := simulateRace(strengths, simulationCount, random)
+	result.print()
+}
+
+func simulateRace(strengths []driverStrength, simulations int, random *rand.Rand) simulationResult {
+	count := len(strengths)
+	result := simulationResult{
+		drivers: []string{},
+		simulations: simulations,
+		wins: make([]int, count),
+		podiums: make([]int, count),
+		top6: make([]int, count),
+		dnfs: make([]int, count),
+		headToHead: make([][]int, count),
+	}
+	for i, driver := range strengths {
+		result.drivers = append(result.drivers, driver.name)
+		result.headToHead[i] = make([]int, count)
+	}
+	keys := make([]float64, count)
+	order := make([]int, count)
+	for range simulations {
+		for i, driver := range strengths {
+			order[i] = i
+			if random.Float64() < driver.dnfProbability {
+				keys[i] = math.Inf(-1)
+				result.dnfs[i]++
+				continue
+			}
+			gumbel := -math.Log(-math.Log(random.Float64()))
+			keys[i] = math.Log(driver.strength) + gumbel
+		}
+		slices.SortFunc(order, func (a, b int) int {
+			return cmp.Compare(keys[b], keys[a])
+		})
+		for position, i := range order {
+			if math.IsInf(keys[i], -1) {
+				break
+			}
+			if position == 0 {
+				result.wins[i]++
+			}
+			if position < podiumPositions {
+				result.podiums[i]++
+			}
+			if position < pointsPositions {
+				result.top6[i]++
+			}
+			for _, j := range order[position + 1:] {
+				result.headToHead[i][j]++
+			}
+		}
+	}
+	return result
+}
+
+func getRatingStrengths() []driverStrength {
+	_, classifications := loadClassifications()
+	ratings := computeRatings(classifications)
+	if len(ratings.history) == 0 {
+		log.Fatal("No race classifications available")
+	}
+	field := ratings.history[len(ratings.history) - 1].field
+	strengths := []driverStrength{}
+	for _, driver := range field {
+		strength := driverStrength{
+			name: driver,
+			strength: getEloStrength(getRating(ratings.drivers, driver)),
+			dnfProbability: getDNFProbability(driver, classifications),
+		}
+		strengths = append(strengths, strength)
+	}
+	return strengths
+}
+
+func getDNFProbability(driver string, classifications []raceClassification) float64 {
+	ordered := slices.Clone(classifications)
+	slices.SortFunc(ordered, func (a, b raceClassification) int {
+		if a.season != b.season {
+			return cmp.Compare(b.season, a.season)
+		}
+		return cmp.Compare(b.id, a.id)
+	})
+	races := 0
+	retirements := 0
+	for _, classification := range ordered {
+		entry, exists := commons.Find(classification.entries, func (e classificationEntry) bool {
+			return e.driver == driver
+		})
+		if !exists {
+			continue
+		}
+		if entry.result != resultPosition {
+			retirements++
+		}
+		races++
+		if races >= raceWindowSize {
+			break
+		}
+	}
+	if races == 0 {
+		return defaultDNFProbability
+	}
+	return max(float64(retirements) / float64(races), minDNFProbability)
+}
+
+func getMarketStrengths(racePath string, snapshot string) []driverStrength {
+	stratType := getStrategyType(snapshot)
+	loadConfiguration()
+	if len(configuration.Races) == 0 {
+		log.Fatal("No races in configuration")
+	}
+	raceConfig := configuration.Races[len(configuration.Races) - 1]
+	if racePath != "" {
+		raceConfig = getRaceConfiguration(racePath)
+	}
+	db := openCache()
+	race := loadRace(db, raceConfig)
+	closeStore(db)
+	race = race.filter(familyWin, getVenue("").Name)
+	strengths := []driverStrength{}
+	for _, driver := range race.drivers {
+		price := driver.getPrice(stratType)
+		if price <= 0.0 {
+			continue
+		}
+		strength := driverStrength{
+			name: driver.name,
+			strength: price,
+			dnfProbability: 0.0,
+		}
+		strengths = append(strengths, strength)
+	}
+	return strengths
+}
+
+func getModelStrengths() []driverStrength {
+	drivers := loadRegressionData()
+	races := getFieldRaces(drivers)
+	model := fitConditionalLogit(races)
+	upcomingRace := getUpcomingFieldRace(drivers)
+	probabilities := model.predict(upcomingRace.features)
+	dnfIndex := slices.Index(driverFeatureNames, "DNF rate")
+	strengths := []driverStrength{}
+	for i, driver := range upcomingRace.drivers {
+		strength := driverStrength{
+			name: driver,
+			strength: probabilities[i],
+			dnfProbability: max(upcomingRace.features[i][dnfIndex], minDNFProbability),
+		}
+		strengths = append(strengths, strength)
+	}
+	return strengths
+}
+
+func (r *simulationResult) print() {
+	indexes := []int{}
+	for i := range r.drivers {
+		indexes = append(indexes, i)
+	}
+	slices.SortFunc(indexes, func (a, b int) int {
+		return cmp.Compare(r.wins[b], r.wins[a])
+	})
+	getRatio := func (count int) float64 {
+		return float64(count) / float64(r.simulations)
+	}
+	fullField := len(r.drivers) > pointsPositions
+	fmt.Printf("Simulated %d races:\n", r.simulations)
+	if fullField {
+		fmt.Printf("\t%-30s %7s %7s %7s %7s\n", "Driver", "Win", "Podium", "Top 6", "DNF")
+	} else {
+		fmt.Printf("\tOnly %d drivers in the field, omitting podium and top 6 probabilities\n", len(r.drivers))
+		fmt.Printf("\t%-30s %7s %7s\n", "Driver", "Win", "DNF")
+	}
+	for _, i := range indexes {
+		if fullField {
+			fmt.Printf("\t%-30s %7.3f %7.3f %7.3f %7.3f\n", r.drivers[i], getRatio(r.wins[i]), getRatio(r.podiums[i]), getRatio(r.top6[i]), getRatio(r.dnfs[i]))
+		} else {
+			fmt.Printf("\t%-30s %7.3f %7.3f\n", r.drivers[i], getRatio(r.wins[i]), getRatio(r.dnfs[i]))
+		}
+	}
+	fmt.Printf("\nHead-to-head:\n")
+	limit := min(headToHeadLimit, len(indexes))
+	for a, i := range indexes[:limit] {
+		for _, j := range indexes[a + 1:limit] {
+			fmt.Printf("\t%s ahead of %s: %.3f\n", r.drivers[i], r.drivers[j], getRatio(r.headToHead[i][j]))
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+const (
+	policyLast = "last"
+	policyTWAP = "twap"
+	policyMedian = "median"
+	policyInterpolate = "interpolate"
+)
+
+type SnapshotConfiguration struct {
+	Policy string `yaml:"policy"`
+	Window time.Duration `yaml:"window"`
+	MaxStaleness time.Duration `yaml:"maxStaleness"`
+}
+
+type snapshotPrice struct {
+	price float64
+	quote priceQuote
+	missing bool
+}
+
+func (s *SnapshotConfiguration) validate() {
+	switch s.Policy {
+	case "":
+		s.Policy = policyLast
+	case policyLast, policyInterpolate:
+	case policyTWAP, policyMedian:
+		if s.Window <= 0 {
+			log.Fatalf("Snapshot policy %s requires a window", s.Policy)
+		}
+	default:
+		log.Fatalf("Unknown snapshot policy: %s", s.Policy)
+	}
+	if s.Window < 0 || s.MaxStaleness < 0 {
+		log.Fatalf("Negative snapshot window or staleness limit")
+	}
+}
+
+func (s *SnapshotConfiguration) override(policy SnapshotConfiguration) {
+	if policy.Policy != "" {
+		s.Policy = policy.Policy
+	}
+	if policy.Window > 0 {
+		s.Window = policy.Window
+	}
+	if policy.MaxStaleness > 0 {
+		s.MaxStaleness = policy.MaxStaleness
+	}
+	s.validate()
+}
+
+func (s *SnapshotConfiguration) describe() string {
+	description := s.Policy
+	if s.Policy == policyTWAP || s.Policy == policyMedian {
+		description += fmt.Sprintf(" over %s", s.Window)
+	}
+	if s.MaxStaleness > 0 {
+		description += fmt.Sprintf(", stale after %s", s.MaxStaleness)
+	} else {
+		description += ", no staleness limit"
+	}
+	return description
+}
+
+func (s *SnapshotConfiguration) extract(series []pricePoint, sessionTime time.Time) (snapshotPrice, bool) {
+	last := -1
+	for i := range series {
+		if series[i].timestamp.After(sessionTime) {
+			break
+		}
+		last = i
+	}
+	if last == -1 || last == len(series) - 1 {
+		return snapshotPrice{}, false
+	}
+	previous := series[last]
+	next := series[last + 1]
+	snapshot := snapshotPrice{
+		price: previous.price,
+		quote: previous.quote,
+	}
+	switch s.Policy {
+	case policyTWAP:
+		snapshot.price = getTimeWeightedPrice(series[:last + 1], sessionTime.Add(-s.Window), sessionTime)
+	case policyMedian:
+		snapshot.price = getMedianPrice(series[:last + 1], sessionTime.Add(-s.Window))
+	case policyInterpolate:
+		duration := next.timestamp.Sub(previous.timestamp)
+		weight := float64(sessionTime.Sub(previous.timestamp)) / float64(duration)
+		snapshot.price = previous.price + weight * (next.price - previous.price)
+	}
+	if s.MaxStaleness > 0 && sessionTime.Sub(previous.timestamp) > s.MaxStaleness {
+		snapshot.missing = true
+	}
+	return snapshot, true
+}
+
+func getTimeWeightedPrice(series []pricePoint, start time.Time, end time.Time) float64 {
+	total := 0.0
+	duration := 0.0
+	for i, point := range series {
+		from := point.timestamp
+		if from.Before(start) {
+			from = start
+		}
+		to := end
+		if i + 1 < len(series) {
+			to = series[i + 1].timestamp
+		}
+		if !to.After(from) {
+			continue
+		}
+		weight := to.Sub(from).Seconds()
+		total += weight * point.price
+		duration += weight
+	}
+	if duration == 0.0 {
+		return series[len(series) - 1].price
+	}
+	return total / duration
+}
+
+func getMedianPrice(series []pricePoint, start time.Time) float64 {
+	prices := []float64{}
+	for _, point := range series {
+		if point.timestamp.After(start) {
+			prices = append(prices, point.price)
+		}
+	}
+	if len(prices) == 0 {
+		return series[len(series) - 1].price
+	}
+	slices.Sort(prices)
+	return stat.Quantile(0.5, stat.Empirical, prices, nil)
+}
\ No newline at end of file
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	storePath = "data/gridlock.db"
+	storeTimeout = 5 * time.Second
+	storeWriteTimeout = 1 * time.Second
+	bucketRaces = "races"
+	bucketSeries = "series"
+	bucketMarkets = "markets"
+	bucketSeasons = "seasons"
+	bucketClassifications = "classifications"
+	marketCacheVersion = 2
+)
+
+type storedRace struct {
+	Path string `json:"path"`
+	Practice time.Time `json:"practice"`
+	Qualifying time.Time `json:"qualifying"`
+	Race time.Time `json:"race"`
+	Metadata RaceMetadata `json:"metadata"`
+	Venues []string `json:"venues"`
+}
+
+type storedMarket struct {
+	Path string `json:"path"`
+	File storedFingerprint `json:"file"`
+	Race string `json:"race"`
+	Venue string `json:"venue"`
+	Parser string `json:"parser"`
+	Sessions string `json:"sessions"`
+	Driver storedDriverData `json:"driver"`
+}
+
+type storedDriverData struct {
+	Name string `json:"name"`
+	Family string `json:"family"`
+	Venue string `json:"venue"`
+	PracticePrice float64 `json:"practicePrice"`
+	QualifyingPrice float64 `json:"qualifyingPrice"`
+	RacePrice float64 `json:"racePrice"`
+	FinalPrice float64 `json:"finalPrice"`
+	PracticeQuote storedQuote `json:"practiceQuote"`
+	QualifyingQuote storedQuote `json:"qualifyingQuote"`
+	RaceQuote storedQuote `json:"raceQuote"`
+	PracticeMissing bool `json:"practiceMissing,omitempty"`
+	QualifyingMissing bool `json:"qualifyingMissing,omitempty"`
+	RaceMissing bool `json:"raceMissing,omitempty"`
+	Winner bool `json:"winner"`
+	Void bool `json:"void"`
+}
+
+type storedQuote struct {
+	Bid float64 `json:"bid,omitempty"`
+	Ask float64 `json:"ask,omitempty"`
+	Exists bool `json:"exists,omitempty"`
+}
+
+type storedSeries struct {
+	Race string `json:"race"`
+	Venue string `json:"venue"`
+	File string `json:"file"`
+	Points []storedPoint `json:"points"`
+}
+
+type storedPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Price float64 `json:"price"`
+	Bid float64 `json:"bid,omitempty"`
+	Ask float64 `json:"ask,omitempty"`
+	Quote bool `json:"quote,omitempty"`
+}
+
+type storedSeason struct {
+	Season int `json:"season"`
+	Path string `json:"path"`
+	File storedFingerprint `json:"file"`
+	Drivers []storedDriver `json:"drivers"`
+	Events []storedEvent `json:"events"`
+}
+
+type storedDriver struct {
+	Name string `json:"name"`
+	Races []storedDriverResult `json:"races"`
+}
+
+type storedDriverResult struct {
+	Season int `json:"season"`
+	ID int `json:"id"`
+	Result raceResult `json:"result"`
+	Position int `json:"position"`
+	Pole bool `json:"pole"`
+}
+
+type storedEvent struct {
+	Season int `json:"season"`
+	ID int `json:"id"`
+	Code string `json:"code"`
+	URL string `json:"url"`
+	Path string `json:"path"`
+}
+
+type storedClassification struct {
+	Season int `json:"season"`
+	ID int `json:"id"`
+	Path string `json:"path"`
+	File storedFingerprint `json:"file"`
+	Entries []storedEntry `json:"entries"`
+}
+
+type storedEntry struct {
+	Driver string `json:"driver"`
+	Constructor string `json:"constructor"`
+	Qualifying int `json:"qualifying"`
+	Grid int `json:"grid"`
+	Result raceResult `json:"result"`
+	Position int `json:"position"`
+}
+
+var cacheRequired = false
+
+func ingest() {
+	loadConfiguration()
+	cacheRequired = true
+	races := loadRaces()
+	markets := 0
+	for _, race := range races {
+		markets += len(race.drivers)
+	}
+	_, events, classifications := loadResults()
+	format := "Store %s is up to date with %d races, %d markets, %d events and %d classifications\n"
+	fmt.Printf(format, storePath, len(races), markets, len(events), len(classifications))
+}
+
+func openStore() *bolt.DB {
+	options := &bolt.Options{
+		Timeout: storeTimeout,
+		ReadOnly: true,
+	}
+	db, err := bolt.Open(storePath, 0644, options)
+	if err != nil {
+		log.Fatalf("Failed to open store %s: %v", storePath, err)
+	}
+	return db
+}
+
+func openCache() *bolt.DB {
+	commons.CreateDirectory(filepath.Dir(storePath))
+	options := &bolt.Options{
+		Timeout: storeWriteTimeout,
+	}
+	db, err := bolt.Open(storePath, 0644, options)
+	if err == nil {
+		return db
+	}
+	if cacheRequired || !errors.Is(err, bolt.ErrTimeout) {
+		log.Fatalf("Failed to open store %s: %v", storePath, err)
+	}
+	log.Printf("Store %s is in use by another process, parsing the data without the cache", storePath)
+	return nil
+}
+
+func closeStore(db *bolt.DB) {
+	if db != nil {
+		db.Close()
+	}
+}
+
+func viewStore(db *bolt.DB, view func (*bolt.Tx) error) error {
+	if db == nil {
+		return nil
+	}
+	return db.View(view)
+}
+
+func updateStore(db *bolt.DB, update func (*bolt.Tx) error) error {
+	if db == nil {
+		return nil
+	}
+	return db.Update(update)
+}
+
+func putRecord(tx *bolt.Tx, bucket string, key string, record any) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	bucketHandle, err := tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+	return bucketHandle.Put([]byte(key), data)
+}
+
+func getRecord(tx *bolt.Tx, bucket string, key string, record any) bool {
+	bucketHandle := tx.Bucket([]byte(bucket))
+	if bucketHandle == nil {
+		return false
+	}
+	data := bucketHandle.Get([]byte(key))
+	if data == nil {
+		return false
+	}
+	err := json.Unmarshal(data, record)
+	if err != nil {
+		log.Printf("Discarding invalid record %s in bucket %s: %v", key, bucket, err)
+		return false
+	}
+	return true
+}
+
+func getSeriesKey(race string, venue string, fileName string) string {
+	return strings.Join([]string{race, venue, fileName}, "/")
+}
+
+func getClassificationKey(season int, id int) string {
+	return fmt.Sprintf("%d-%02d", season, id)
+}
+
+func newStoredDriverData(driver driverData) storedDriverData {
+	return storedDriverData{
+		Name: driver.name,
+		Family: driver.family,
+		Venue: driver.venue,
+		PracticePrice: driver.practicePrice,
+		QualifyingPrice: driver.qualifyingPrice,
+		RacePrice: driver.racePrice,
+		FinalPrice: driver.finalPrice,
+		PracticeQuote: newStoredQuote(driver.practiceQuote),
+		QualifyingQuote: newStoredQuote(driver.qualifyingQuote),
+		RaceQuote: newStoredQuote(driver.raceQuote),
+		PracticeMissing: driver.practiceMissing,
+		QualifyingMissing: driver.qualifyingMissing,
+		RaceMissing: driver.raceMissing,
+		Winner: driver.winner,
+		Void: driver.void,
+	}
+}
+
+func (d *storedDriverData) getDriverData() driverData {
+	return driverData{
+		name: d.Name,
+		family: d.Family,
+		venue: d.Venue,
+		practicePrice: d.PracticePrice,
+		qualifyingPrice: d.QualifyingPrice,
+		racePrice: d.RacePrice,
+		finalPrice: d.FinalPrice,
+		practiceQuote: d.PracticeQuote.getQuote(),
+		qualifyingQuote: d.QualifyingQuote.getQuote(),
+		raceQuote: d.RaceQuote.getQuote(),
+		practiceMissing: d.PracticeMissing,
+		qualifyingMissing: d.QualifyingMissing,
+		raceMissing: d.RaceMissing,
+		winner: d.Winner,
+		void: d.Void,
+	}
+}
+
+func newStoredQuote(quote priceQuote) storedQuote {
+	return storedQuote{
+		Bid: quote.bid,
+		Ask: quote.ask,
+		Exists: quote.exists,
+	}
+}
+
+func (q storedQuote) getQuote() priceQuote {
+	return priceQuote{
+		bid: q.Bid,
+		ask: q.Ask,
+		exists: q.Exists,
+	}
+}
+
+func newStoredSeries(race string, venue string, fileName string, series []pricePoint) storedSeries {
+	points := []storedPoint{}
+	for _, point := range series {
+		stored := storedPoint{
+			Timestamp: point.timestamp,
+			Price: point.price,
+			Bid: point.quote.bid,
+			Ask: point.quote.ask,
+			Quote: point.quote.exists,
+		}
+		points = append(points, stored)
+	}
+	return storedSeries{
+		Race: race,
+		Venue: venue,
+		File: fileName,
+		Points: points,
+	}
+}
+
+func (s *storedSeries) getPricePoints() []pricePoint {
+	series := []pricePoint{}
+	for _, stored := range s.Points {
+		point := pricePoint{
+			timestamp: stored.Timestamp,
+			price: stored.Price,
+			quote: priceQuote{
+				bid: stored.Bid,
+				ask: stored.Ask,
+				exists: stored.Quote,
+			},
+		}
+		series = append(series, point)
+	}
+	return series
+}
+
+func newStoredSeason(season int, path string, file storedFingerprint, drivers []driverSeasonalData, events []wikiEvent) storedSeason {
+	stored := storedSeason{
+		Season: season,
+		Path: path,
+		File: file,
+		Drivers: []storedDriver{},
+		Events: []storedEvent{},
+	}
+	for _, driver := range drivers {
+		storedDriver := storedDriver{
+			Name: driver.name,
+			Races: []storedDriverResult{},
+		}
+		for _, race := range driver.races {
+			result := storedDriverResult{
+				Season: race.season,
+				ID: race.id,
+				Result: race.result,
+				Position: race.position,
+				Pole: race.pole,
+			}
+			storedDriver.Races = append(storedDriver.Races, result)
+		}
+		stored.Drivers = append(stored.Drivers, storedDriver)
+	}
+	for _, event := range events {
+		storedEvent := storedEvent{
+			Season: event.season,
+			ID: event.id,
+			Code: event.code,
+			URL: event.url,
+			Path: event.path,
+		}
+		stored.Events = append(stored.Events, storedEvent)
+	}
+	return stored
+}
+
+func (s *storedSeason) getResults() ([]driverSeasonalData, []wikiEvent) {
+	drivers := []driverSeasonalData{}
+	for _, stored := range s.Drivers {
+		driver := driverSeasonalData{
+			name: stored.Name,
+			races: []driverRaceResult{},
+		}
+		for _, race := range stored.Races {
+			result := driverRaceResult{
+				season: race.Season,
+				id: race.ID,
+				result: race.Result,
+				position: race.Position,
+				pole: race.Pole,
+			}
+			driver.races = append(driver.races, result)
+		}
+		drivers = append(drivers, driver)
+	}
+	events := []wikiEvent{}
+	for _, stored := range s.Events {
+		event := wikiEvent{
+			season: stored.Season,
+			id: stored.ID,
+			code: stored.Code,
+			url: stored.URL,
+			path: stored.Path,
+		}
+		events = append(events, event)
+	}
+	return drivers, events
+}
+
+func newStoredClassification(classification raceClassification, path string, file storedFingerprint) storedClassification {
+	stored := storedClassification{
+		Season: classification.season,
+		ID: classification.id,
+		Path: path,
+		File: file,
+		Entries: []storedEntry{},
+	}
+	for _, entry := range classification.entries {
+		storedEntry := storedEntry{
+			Driver: entry.driver,
+			Constructor: entry.constructor,
+			Qualifying: entry.qualifying,
+			Grid: entry.grid,
+			Result: entry.result,
+			Position: entry.position,
+		}
+		stored.Entries = append(stored.Entries, storedEntry)
+	}
+	return stored
+}
+
+func (s *storedClassification) getClassification() raceClassification {
+	classification := raceClassification{
+		season: s.Season,
+		id: s.ID,
+		entries: []classificationEntry{},
+	}
+	for _, stored := range s.Entries {
+		entry := classificationEntry{
+			driver: stored.Driver,
+			constructor: stored.Constructor,
+			qualifying: stored.Qualifying,
+			grid: stored.Grid,
+			result: stored.Result,
+			position: stored.Position,
+		}
+		classification.entries = append(classification.entries, entry)
+	}
+	return classification
+}
+
+func dumpStore(bucketName string) {
+	if !commons.FileExists(storePath) {
+		log.Fatalf("Store %s does not exist, run the ingest command first", storePath)
+	}
+	db := openStore()
+	defer db.Close()
+	err := db.View(func (tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return fmt.Errorf("unknown bucket \"%s\"", bucketName)
+		}
+		return bucket.ForEach(func (_, value []byte) error {
+			fmt.Println(string(value))
+			return nil
+		})
+	})
+	if err != nil {
+		log.Fatalf("Failed to dump store: %v", err)
+	}
+}
\ No newline at end of file
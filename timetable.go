@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	icalDateTimeLayout = "20060102T150405"
+	icalUTCLayout = "20060102T150405Z"
+	sessionPractice = "practice"
+	sessionQualifying = "qualifying"
+	sessionRace = "race"
+	sessionSprint = "sprint"
+)
+
+type calendarEvent struct {
+	summary string
+	location string
+	start time.Time
+}
+
+type calendarSession struct {
+	session string
+	start time.Time
+}
+
+type calendarWeekend struct {
+	name string
+	location string
+	season int
+	round int
+	sessions []calendarSession
+	practice time.Time
+	qualifying time.Time
+	race time.Time
+	sprint bool
+}
+
+func importTimetable(path string) {
+	loadConfiguration()
+	events := parseCalendar(path)
+	weekends := getCalendarWeekends(events)
+	matched := map[int]string{}
+	seasons := map[int]bool{}
+	changed := 0
+	unchanged := 0
+	for _, weekend := range weekends {
+		seasons[weekend.season] = true
+	}
+	for _, raceConfig := range configuration.Races {
+		if !seasons[raceConfig.Season] {
+			continue
+		}
+		i := findCalendarWeekend(weekends, raceConfig)
+		if i == -1 {
+			log.Printf("No calendar entry for race %s", raceConfig.Path)
+			continue
+		}
+		weekend := weekends[i]
+		previous, exists := matched[i]
+		if exists {
+			log.Printf("Calendar entry %s %d of race %s was already matched by race %s", weekend.name, weekend.season, raceConfig.Path, previous)
+			continue
+		}
+		matched[i] = raceConfig.Path
+		if weekend.printDiff(raceConfig) {
+			changed++
+		} else {
+			unchanged++
+		}
+	}
+	added := 0
+	for i, weekend := range weekends {
+		_, exists := matched[i]
+		if !exists {
+			weekend.printEntry()
+			added++
+		}
+	}
+	fmt.Printf("%d races changed, %d unchanged, %d new\n", changed, unchanged, added)
+}
+
+func parseCalendar(path string) []calendarEvent {
+	data := string(commons.ReadFile(path))
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+	defaultLocation := time.UTC
+	events := []calendarEvent{}
+	var event *calendarEvent
+	for _, line := range strings.Split(data, "\n") {
+		nameParameters, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		tokens := strings.Split(nameParameters, ";")
+		name := strings.ToUpper(tokens[0])
+		parameters := map[string]string{}
+		for _, token := range tokens[1:] {
+			key, parameterValue, _ := strings.Cut(token, "=")
+			parameters[strings.ToUpper(key)] = strings.Trim(parameterValue, "\"")
+		}
+		switch name {
+		case "X-WR-TIMEZONE":
+			defaultLocation = getLocation(value)
+		case "BEGIN":
+			if value == "VEVENT" {
+				event = &calendarEvent{}
+			}
+		case "END":
+			if value == "VEVENT" && event != nil {
+				if !event.start.IsZero() {
+					events = append(events, *event)
+				}
+				event = nil
+			}
+		case "SUMMARY":
+			if event != nil {
+				event.summary = unescapeCalendarText(value)
+			}
+		case "LOCATION":
+			if event != nil {
+				event.location = unescapeCalendarText(value)
+			}
+		case "DTSTART":
+			if event != nil && parameters["VALUE"] != "DATE" {
+				location := defaultLocation
+				zone, exists := parameters["TZID"]
+				if exists {
+					location = getLocation(zone)
+				}
+				event.start = parseCalendarTime(value, location)
+			}
+		}
+	}
+	if len(events) == 0 {
+		log.Fatalf("No events found in calendar %s", path)
+	}
+	return events
+}
+
+func getLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("Unknown time zone %s: %v", name, err)
+	}
+	return location
+}
+
+func parseCalendarTime(value string, location *time.Location) time.Time {
+	var timestamp time.Time
+	var err error
+	if strings.HasSuffix(value, "Z") {
+		timestamp, err = time.Parse(icalUTCLayout, value)
+	} else {
+		timestamp, err = time.ParseInLocation(icalDateTimeLayout, value, location)
+	}
+	if err != nil {
+		log.Fatalf("Failed to parse calendar timestamp %s: %v", value, err)
+	}
+	return timestamp.UTC()
+}
+
+func unescapeCalendarText(value string) string {
+	replacer := strings.NewReplacer("\\,", ",", "\\;", ";", "\\n", " ", "\\N", " ", "\\\\", "\\")
+	return strings.TrimSpace(replacer.Replace(value))
+}
+
+func getCalendarWeekends(events []calendarEvent) []calendarWeekend {
+	weekends := []calendarWeekend{}
+	for _, event := range events {
+		separator := strings.LastIndex(event.summary, " - ")
+		if separator == -1 {
+			log.Printf("Ignoring calendar event without a session name: %s", event.summary)
+			continue
+		}
+		name := getWeekendName(event.summary[:separator])
+		session, exists := getCalendarSession(event.summary[separator + 3:])
+		if !exists {
+			continue
+		}
+		season := event.start.Year()
+		i := slices.IndexFunc(weekends, func (w calendarWeekend) bool {
+			return w.name == name && w.season == season
+		})
+		if i == -1 {
+			weekends = append(weekends, calendarWeekend{
+				name: name,
+				location: event.location,
+				season: season,
+			})
+			i = len(weekends) - 1
+		}
+		weekends[i].sessions = append(weekends[i].sessions, calendarSession{
+			session: session,
+			start: event.start,
+		})
+	}
+	complete := []calendarWeekend{}
+	for _, weekend := range weekends {
+		if weekend.resolveSessions() {
+			complete = append(complete, weekend)
+		} else {
+			log.Printf("Ignoring incomplete calendar weekend %s %d", weekend.name, weekend.season)
+		}
+	}
+	slices.SortFunc(complete, func (a, b calendarWeekend) int {
+		return a.race.Compare(b.race)
+	})
+	for i := range complete {
+		weekend := &complete[i]
+		weekend.round = 1
+		for _, other := range complete[:i] {
+			if other.season == weekend.season {
+				weekend.round++
+			}
+		}
+	}
+	return complete
+}
+
+func getWeekendName(title string) string {
+	slug := getSlug(title)
+	for _, token := range []string{"formula-1", "grand-prix"} {
+		slug = strings.ReplaceAll(slug, token, "")
+	}
+	words := []string{}
+	for _, word := range strings.Split(slug, "-") {
+		if word != "" && strings.Trim(word, "0123456789") != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, "-")
+}
+
+func getCalendarSession(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "sprint"):
+		return sessionSprint, true
+	case strings.Contains(name, "practice") || strings.HasPrefix(name, "fp"):
+		return sessionPractice, true
+	case strings.Contains(name, "qualifying"):
+		return sessionQualifying, true
+	case strings.Contains(name, "race") || strings.Contains(name, "grand prix"):
+		return sessionRace, true
+	}
+	return "", false
+}
+
+func (w *calendarWeekend) resolveSessions() bool {
+	slices.SortFunc(w.sessions, func (a, b calendarSession) int {
+		return a.start.Compare(b.start)
+	})
+	for _, session := range w.sessions {
+		switch session.session {
+		case sessionQualifying:
+			w.qualifying = session.start
+		case sessionRace:
+			w.race = session.start
+		case sessionSprint:
+			w.sprint = true
+		}
+	}
+	for _, session := range w.sessions {
+		if session.session == sessionPractice && session.start.Before(w.qualifying) {
+			w.practice = session.start
+		}
+	}
+	return !w.practice.IsZero() && !w.qualifying.IsZero() && !w.race.IsZero()
+}
+
+func findCalendarWeekend(weekends []calendarWeekend, raceConfig RaceConfiguration) int {
+	matchers := []func (w calendarWeekend) bool{
+		func (w calendarWeekend) bool {
+			return w.matchesName(raceConfig.Path)
+		},
+		func (w calendarWeekend) bool {
+			return w.matchesName(raceConfig.Circuit) || (raceConfig.Circuit != "" && getWeekendName(w.location) == getWeekendName(raceConfig.Circuit))
+		},
+		func (w calendarWeekend) bool {
+			return raceConfig.Round > 0 && w.round == raceConfig.Round
+		},
+		func (w calendarWeekend) bool {
+			return w.matchesName(raceConfig.Country)
+		},
+	}
+	for _, matcher := range matchers {
+		i := slices.IndexFunc(weekends, func (w calendarWeekend) bool {
+			return w.season == raceConfig.Season && matcher(w)
+		})
+		if i != -1 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *calendarWeekend) matchesName(name string) bool {
+	name = getWeekendName(name)
+	if name == "" {
+		return false
+	}
+	return strings.Contains("-" + w.name + "-", "-" + name + "-")
+}
+
+func (w *calendarWeekend) printDiff(raceConfig RaceConfiguration) bool {
+	type diffField struct {
+		name string
+		previous string
+		current string
+	}
+	location := raceConfig.getLocation()
+	fields := []diffField{
+		{
+			name: "round",
+			previous: raceConfig.getField(metadataRound),
+			current: fmt.Sprintf("%d", w.round),
+		},
+		{
+			name: "sprint",
+			previous: raceConfig.getField(metadataSprint),
+			current: fmt.Sprintf("%t", w.sprint),
+		},
+		{
+			name: "practice",
+			previous: raceConfig.Practice.In(location).Format(timeLayout),
+			current: w.practice.In(location).Format(timeLayout),
+		},
+		{
+			name: "qualifying",
+			previous: raceConfig.Qualifying.In(location).Format(timeLayout),
+			current: w.qualifying.In(location).Format(timeLayout),
+		},
+		{
+			name: "race",
+			previous: raceConfig.Race.In(location).Format(timeLayout),
+			current: w.race.In(location).Format(timeLayout),
+		},
+	}
+	fields = slices.DeleteFunc(fields, func (f diffField) bool {
+		return f.previous == f.current
+	})
+	if len(fields) == 0 {
+		return false
+	}
+	fmt.Printf("%s:\n", raceConfig.Path)
+	for _, field := range fields {
+		if field.previous != "" {
+			fmt.Printf("-\t%s: %s\n", field.name, field.previous)
+		}
+		fmt.Printf("+\t%s: %s\n", field.name, field.current)
+	}
+	return true
+}
+
+func (w *calendarWeekend) printEntry() {
+	lines := []string{
+		fmt.Sprintf("- path: %d-%s", w.season, w.name),
+		fmt.Sprintf("  round: %d", w.round),
+	}
+	if w.location != "" {
+		lines = append(lines, fmt.Sprintf("  circuit: %s", w.location))
+	}
+	if w.sprint {
+		lines = append(lines, "  sprint: true")
+	}
+	lines = append(lines,
+		fmt.Sprintf("  practice: %s", w.practice.Format(timeLayout)),
+		fmt.Sprintf("  qualifying: %s", w.qualifying.Format(timeLayout)),
+		fmt.Sprintf("  race: %s", w.race.Format(timeLayout)),
+	)
+	for _, line := range lines {
+		fmt.Printf("+ %s\n", line)
+	}
+}
\ No newline at end of file
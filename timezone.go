@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	offsetLayout = "-07:00"
+)
+
+var offsetTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05 -0700",
+}
+
+func parseTimeZone(name string) *time.Location {
+	if name == "" || strings.EqualFold(name, "UTC") {
+		return time.UTC
+	}
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		offset, err := time.Parse(offsetLayout, name)
+		if err != nil {
+			log.Fatalf("Invalid UTC offset, expected format +hh:mm: %s", name)
+		}
+		_, seconds := offset.Zone()
+		return time.FixedZone(name, seconds)
+	}
+	return getLocation(name)
+}
+
+func getWallClockTime(timestamp time.Time, location *time.Location) time.Time {
+	return time.Date(
+		timestamp.Year(),
+		timestamp.Month(),
+		timestamp.Day(),
+		timestamp.Hour(),
+		timestamp.Minute(),
+		timestamp.Second(),
+		timestamp.Nanosecond(),
+		location,
+	)
+}
+
+func parseOffsetTimestamp(timestampString string) (time.Time, bool) {
+	for _, layout := range offsetTimestampLayouts {
+		timestamp, err := time.Parse(layout, timestampString)
+		if err == nil {
+			return timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func hasSameOffset(timestamp time.Time, location *time.Location) bool {
+	_, offset1 := timestamp.Zone()
+	_, offset2 := timestamp.In(location).Zone()
+	return offset1 == offset2
+}
+
+func (r *RaceConfiguration) validateTimeZone() {
+	r.location = parseTimeZone(r.TimeZone)
+	for _, t := range []*SerializableTime{r.Practice, r.Qualifying, r.Race} {
+		if t.explicitOffset {
+			if r.TimeZone != "" && !hasSameOffset(t.Time, r.location) {
+				log.Fatalf("Timestamp %s disagrees with time zone %s in race configuration: %s", t.Format(timeZoneLayout), r.TimeZone, r.Path)
+			}
+		} else {
+			t.Time = getWallClockTime(t.Time, r.location)
+		}
+	}
+}
+
+func (r *RaceConfiguration) getLocation() *time.Location {
+	if r.location == nil {
+		return time.UTC
+	}
+	return r.location
+}
+
+func (v *VenueConfiguration) getLocation() *time.Location {
+	if v.location == nil {
+		return time.UTC
+	}
+	return v.location
+}
\ No newline at end of file
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+)
+
+const (
+	validationKFold = "kfold"
+	validationTime = "time"
+	validationResultLimit = 10
+	probabilityEpsilon = 1e-15
+)
+
+var (
+	alphaValues = []float64{0.0001, 0.001, 0.01}
+	regularizationValues = []float64{0, 0.001, 0.01}
+	maxIterationsValues = []int{500, 1000, 2000}
+	classThresholdValues = []float64{0.30, 0.40, 0.50}
+	raceWindowSizeValues = []int{5, 10}
+)
+
+type hyperparameters struct {
+	backend string
+	alpha float64
+	regularization float64
+	maxIterations int
+	classThreshold float64
+	raceWindowSize int
+}
+
+type classificationMetrics struct {
+	samples int
+	truePositives int
+	trueNegatives int
+	falsePositives int
+	falseNegatives int
+	positiveLabels int
+	youdensJ float64
+	f1Score float64
+	rocAUC float64
+	logLoss float64
+	brierScore float64
+}
+
+type validationResult struct {
+	parameters hyperparameters
+	metrics classificationMetrics
+}
+
+type raceKey struct {
+	season int
+	id int
+}
+
+func getDefaultHyperparameters(backend string) hyperparameters {
+	return hyperparameters{
+		backend: backend,
+		alpha: alpha,
+		regularization: regularization,
+		maxIterations: maxIterations,
+		classThreshold: classThreshold,
+		raceWindowSize: raceWindowSize,
+	}
+}
+
+func searchHyperparameters(set featureSet, drivers []driverSeasonalData, options regressionOptions) regressionEvaluation {
+	validation := options.validation
+	folds := options.folds
+	if validation != validationKFold && validation != validationTime {
+		log.Fatalf("Unknown validation method: %s", validation)
+	}
+	if folds < 2 {
+		log.Fatalf("Invalid number of folds: %d", folds)
+	}
+	searchAlphaValues, searchRegularizationValues, searchMaxIterationsValues := getSearchValues(options.backend)
+	results := []validationResult{}
+	for _, windowSize := range raceWindowSizeValues {
+		features, labels, metaData := getFeatures(drivers, set, windowSize)
+		blocks := getFoldBlocks(metaData, validation, folds)
+		for _, alphaValue := range searchAlphaValues {
+			for _, regularizationValue := range searchRegularizationValues {
+				for _, iterations := range searchMaxIterationsValues {
+					parameters := hyperparameters{
+						backend: options.backend,
+						alpha: alphaValue,
+						regularization: regularizationValue,
+						maxIterations: iterations,
+						raceWindowSize: windowSize,
+					}
+					probabilities, outOfFoldLabels := getOutOfFoldPredictions(features, labels, metaData, blocks, validation, folds, parameters)
+					for _, threshold := range classThresholdValues {
+						parameters.classThreshold = threshold
+						result := validationResult{
+							parameters: parameters,
+							metrics: getClassificationMetrics(probabilities, outOfFoldLabels, threshold),
+						}
+						results = append(results, result)
+					}
+				}
+			}
+		}
+	}
+	slices.SortFunc(results, func (a, b validationResult) int {
+		if a.metrics.rocAUC != b.metrics.rocAUC {
+			return cmp.Compare(b.metrics.rocAUC, a.metrics.rocAUC)
+		}
+		return cmp.Compare(b.metrics.youdensJ, a.metrics.youdensJ)
+	})
+	fmt.Printf("Out-of-fold results (%s, %d folds):\n", validation, folds)
+	fmt.Printf("\t%-8s %-14s %10s %9s %6s %8s %11s %9s %8s %9s %8s\n", "Alpha", "Regularization", "Iterations", "Threshold", "Window", "Samples", "Youden's J", "F1 score", "ROC AUC", "Log loss", "Brier")
+	for i, result := range results {
+		if i >= validationResultLimit {
+			break
+		}
+		parameters := result.parameters
+		metrics := result.metrics
+		fmt.Printf(
+			"\t%-8g %-14g %10d %9.2f %6d %8d %11.3f %9.3f %8.3f %9.3f %8.3f\n",
+			parameters.alpha,
+			parameters.regularization,
+			parameters.maxIterations,
+			parameters.classThreshold,
+			parameters.raceWindowSize,
+			metrics.samples,
+			metrics.youdensJ,
+			metrics.f1Score,
+			metrics.rocAUC,
+			metrics.logLoss,
+			metrics.brierScore,
+		)
+	}
+	best := results[0]
+	fmt.Printf("Best hyperparameters:\n")
+	best.metrics.print()
+	format := "Train with: train -features %s -backend %s -alpha %g -regularization %g -iterations %d -races %d -model <path>\n"
+	fmt.Printf(format, set.name, options.backend, best.parameters.alpha, best.parameters.regularization, best.parameters.maxIterations, best.parameters.raceWindowSize)
+	evaluation := regressionEvaluation{
+		set: set,
+		metrics: best.metrics,
+	}
+	return evaluation
+}
+
+func getSearchValues(backend string) ([]float64, []float64, []int) {
+	switch backend {
+	case backendGonum:
+		return []float64{alpha}, regularizationValues, maxIterationsValues
+	case backendBoost, backendElo:
+		return []float64{alpha}, []float64{regularization}, []int{maxIterations}
+	}
+	return alphaValues, regularizationValues, maxIterationsValues
+}
+
+func getFoldBlocks(metaData []featureMetaData, validation string, folds int) []int {
+	races := []raceKey{}
+	for _, currentMetaData := range metaData {
+		key := raceKey{
+			season: currentMetaData.season,
+			id: currentMetaData.id,
+		}
+		if !slices.Contains(races, key) {
+			races = append(races, key)
+		}
+	}
+	slices.SortFunc(races, func (a, b raceKey) int {
+		if a.season != b.season {
+			return cmp.Compare(a.season, b.season)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	blockCount := folds
+	if validation == validationTime {
+		blockCount = folds + 1
+	}
+	if len(races) < blockCount {
+		log.Fatalf("Not enough races for %d folds: %d", folds, len(races))
+	}
+	blocks := []int{}
+	for _, currentMetaData := range metaData {
+		i := slices.IndexFunc(races, func (r raceKey) bool {
+			return r.season == currentMetaData.season && r.id == currentMetaData.id
+		})
+		var block int
+		if validation == validationKFold {
+			block = i % blockCount
+		} else {
+			block = i * blockCount / len(races)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func getOutOfFoldPredictions(
+	features [][]float64,
+	labels []float64,
+	metaData []featureMetaData,
+	blocks []int,
+	validation string,
+	folds int,
+	parameters hyperparameters,
+) ([]float64, []float64) {
+	probabilities := []float64{}
+	outOfFoldLabels := []float64{}
+	firstFold := 0
+	if validation == validationTime {
+		firstFold = 1
+	}
+	for fold := firstFold; fold < firstFold + folds; fold++ {
+		isTraining := func (block int) bool {
+			if validation == validationKFold {
+				return block != fold
+			}
+			return block < fold
+		}
+		trainingFeatures := [][]float64{}
+		trainingLabels := []float64{}
+		trainingMetaData := []featureMetaData{}
+		for i, block := range blocks {
+			if isTraining(block) {
+				trainingFeatures = append(trainingFeatures, features[i])
+				trainingLabels = append(trainingLabels, labels[i])
+				trainingMetaData = append(trainingMetaData, metaData[i])
+			}
+		}
+		model := newModel(parameters)
+		model.fit(trainingFeatures, trainingLabels, trainingMetaData)
+		for i, block := range blocks {
+			if block != fold {
+				continue
+			}
+			probability := model.predict(features[i], metaData[i])
+			probabilities = append(probabilities, probability)
+			outOfFoldLabels = append(outOfFoldLabels, labels[i])
+		}
+	}
+	return probabilities, outOfFoldLabels
+}
+
+func getClassificationMetrics(probabilities []float64, labels []float64, threshold float64) classificationMetrics {
+	metrics := classificationMetrics{
+		samples: len(labels),
+	}
+	logLoss := 0.0
+	brierScore := 0.0
+	for i, probability := range probabilities {
+		label := labels[i] == 1.0
+		prediction := probability > threshold
+		if label {
+			if prediction {
+				metrics.truePositives++
+			} else {
+				metrics.falseNegatives++
+			}
+			metrics.positiveLabels++
+		} else {
+			if prediction {
+				metrics.falsePositives++
+			} else {
+				metrics.trueNegatives++
+			}
+		}
+		clipped := min(max(probability, probabilityEpsilon), 1.0 - probabilityEpsilon)
+		logLoss -= labels[i] * math.Log(clipped) + (1.0 - labels[i]) * math.Log(1.0 - clipped)
+		brierScore += math.Pow(probability - labels[i], 2.0)
+	}
+	truePositives := float64(metrics.truePositives)
+	trueNegatives := float64(metrics.trueNegatives)
+	falsePositives := float64(metrics.falsePositives)
+	falseNegatives := float64(metrics.falseNegatives)
+	positiveTerm := truePositives / (truePositives + falseNegatives)
+	negativeTerm := trueNegatives / (trueNegatives + falsePositives)
+	metrics.youdensJ = positiveTerm + negativeTerm - 1.0
+	metrics.f1Score = 2.0 * truePositives / (2.0 * truePositives + falsePositives + falseNegatives)
+	metrics.rocAUC = getROCAUC(probabilities, labels)
+	metrics.logLoss = logLoss / float64(metrics.samples)
+	metrics.brierScore = brierScore / float64(metrics.samples)
+	return metrics
+}
+
+func getROCAUC(probabilities []float64, labels []float64) float64 {
+	indexes := []int{}
+	for i := range probabilities {
+		indexes = append(indexes, i)
+	}
+	slices.SortFunc(indexes, func (a, b int) int {
+		return cmp.Compare(probabilities[a], probabilities[b])
+	})
+	rankSum := 0.0
+	positives := 0
+	for i := 0; i < len(indexes); {
+		j := i
+		for j < len(indexes) && probabilities[indexes[j]] == probabilities[indexes[i]] {
+			j++
+		}
+		rank := float64(i + j + 1) / 2.0
+		for _, index := range indexes[i:j] {
+			if labels[index] == 1.0 {
+				rankSum += rank
+				positives++
+			}
+		}
+		i = j
+	}
+	negatives := len(indexes) - positives
+	if positives == 0 || negatives == 0 {
+		return math.NaN()
+	}
+	return (rankSum - float64(positives * (positives + 1)) / 2.0) / float64(positives * negatives)
+}
+
+func (m *classificationMetrics) print() {
+	printRatio := func (description string, count int) {
+		percentage := 100.0 * float64(count) / float64(m.samples)
+		fmt.Printf("%s: %.1f%% (%d samples)\n", description, percentage, count)
+	}
+	printRatio("True positives", m.truePositives)
+	printRatio("True negatives", m.trueNegatives)
+	printRatio("False positives", m.falsePositives)
+	printRatio("False negatives", m.falseNegatives)
+	printRatio("True labels", m.positiveLabels)
+	fmt.Printf("Youden's J: %.3f\n", m.youdensJ)
+	fmt.Printf("F1 score: %.3f\n", m.f1Score)
+	fmt.Printf("ROC AUC: %.3f\n", m.rocAUC)
+	fmt.Printf("Log loss: %.3f\n", m.logLoss)
+	fmt.Printf("Brier score: %.3f\n", m.brierScore)
+}
\ No newline at end of file
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	formatProbability = "probability"
+	formatDecimal = "decimal"
+	formatFractional = "fractional"
+	defaultVenueName = "default"
+)
+
+type VenueConfiguration struct {
+	Name string `yaml:"name"`
+	Directory string `yaml:"directory"`
+	Format string `yaml:"format"`
+	TimestampLayout string `yaml:"timestampLayout"`
+	TimestampColumn int `yaml:"timestampColumn"`
+	PriceColumn int `yaml:"priceColumn"`
+	Family string `yaml:"family"`
+	NamePattern string `yaml:"namePattern"`
+	Execution string `yaml:"execution"`
+	Spread float64 `yaml:"spread"`
+	RelativeSpread float64 `yaml:"relativeSpread"`
+	Depth float64 `yaml:"depth"`
+	DepthSlippage float64 `yaml:"depthSlippage"`
+	WinningsFee float64 `yaml:"winningsFee"`
+	TradeFee float64 `yaml:"tradeFee"`
+	MaxFillPrice float64 `yaml:"maxFillPrice"`
+	TimeZone string `yaml:"timeZone"`
+	namePattern *regexp.Regexp
+	location *time.Location
+}
+
+type pricePoint struct {
+	timestamp time.Time
+	price float64
+	quote priceQuote
+}
+
+func getDefaultVenue() VenueConfiguration {
+	return VenueConfiguration{
+		Name: defaultVenueName,
+		Format: formatProbability,
+		Execution: executionFlat,
+		Spread: spread,
+	}
+}
+
+func getVenue(name string) VenueConfiguration {
+	if name == "" {
+		if configuration != nil && len(configuration.Venues) > 0 {
+			return configuration.Venues[0]
+		}
+		return getDefaultVenue()
+	}
+	i := slices.IndexFunc(configuration.Venues, func (v VenueConfiguration) bool {
+		return v.Name == name
+	})
+	if i == -1 {
+		log.Fatalf("Unable to find venue in configuration: %s", name)
+	}
+	return configuration.Venues[i]
+}
+
+func (r *RaceConfiguration) getVenues() []VenueConfiguration {
+	if len(r.Venues) == 0 {
+		return []VenueConfiguration{getVenue("")}
+	}
+	venues := []VenueConfiguration{}
+	for _, name := range r.Venues {
+		venue := getVenue(name)
+		venues = append(venues, venue)
+	}
+	return venues
+}
+
+func (v *VenueConfiguration) validate() {
+	if v.Name == "" {
+		log.Fatalf("Name missing from venue configuration")
+	}
+	switch v.Format {
+	case "":
+		v.Format = formatProbability
+	case formatProbability, formatDecimal, formatFractional:
+	default:
+		log.Fatalf("Unknown price format in venue configuration %s: %s", v.Name, v.Format)
+	}
+	if v.NamePattern != "" {
+		pattern, err := regexp.Compile(v.NamePattern)
+		if err != nil || pattern.NumSubexp() != 1 {
+			log.Fatalf("Invalid name pattern in venue configuration %s: %s", v.Name, v.NamePattern)
+		}
+		v.namePattern = pattern
+		if v.Family == "" {
+			v.Family = familyWin
+		}
+	}
+	if v.Family != "" {
+		_ = getMarketFamily(v.Family)
+	}
+	if v.WinningsFee < 0.0 || v.WinningsFee >= 1.0 {
+		log.Fatalf("Invalid winnings fee in venue configuration: %s", v.Name)
+	}
+	if v.Spread < 0.0 || v.RelativeSpread < 0.0 || v.TradeFee < 0.0 {
+		log.Fatalf("Negative spread or fee in venue configuration: %s", v.Name)
+	}
+	if v.MaxFillPrice < 0.0 || v.MaxFillPrice > 1.0 {
+		log.Fatalf("Invalid maximum fill price in venue configuration: %s", v.Name)
+	}
+	if v.Execution == executionDepth && v.Depth <= 0.0 {
+		log.Fatalf("Depth missing from venue configuration: %s", v.Name)
+	}
+	_ = v.getExecutionModel()
+	v.location = parseTimeZone(v.TimeZone)
+}
+
+func (v *VenueConfiguration) matchMarket(fileName string) (marketFamily, string, bool) {
+	if v.namePattern == nil {
+		return matchMarketFamily(fileName)
+	}
+	matches := v.namePattern.FindStringSubmatch(fileName)
+	if matches == nil {
+		return marketFamily{}, "", false
+	}
+	family := getMarketFamily(v.Family)
+	return family, getSlug(matches[1]), true
+}
+
+func (v *VenueConfiguration) parseTimestamp(timestampString string) time.Time {
+	location := v.getLocation()
+	timestampString = strings.TrimSpace(timestampString)
+	var timestamp time.Time
+	if v.TimestampLayout == "" {
+		var explicitOffset bool
+		timestamp, explicitOffset = parseOffsetTimestamp(timestampString)
+		if !explicitOffset {
+			return getWallClockTime(commons.MustParseTime(timestampString), location)
+		}
+	} else {
+		var err error
+		timestamp, err = time.ParseInLocation(v.TimestampLayout, timestampString, location)
+		if err != nil {
+			log.Fatalf("Failed to parse timestamp of venue %s: %s", v.Name, timestampString)
+		}
+	}
+	if v.TimeZone != "" && !hasSameOffset(timestamp, location) {
+		log.Fatalf("Timestamp %s of venue %s disagrees with its time zone %s", timestampString, v.Name, v.TimeZone)
+	}
+	return timestamp
+}
+
+func (v *VenueConfiguration) parsePrice(priceString string) float64 {
+	priceString = strings.TrimSpace(priceString)
+	switch v.Format {
+	case formatDecimal:
+		odds := commons.MustParseFloat(priceString)
+		if odds < 1.0 {
+			log.Fatalf("Invalid decimal odds of venue %s: %s", v.Name, priceString)
+		}
+		return 1.0 / odds
+	case formatFractional:
+		if strings.EqualFold(priceString, "evs") || strings.EqualFold(priceString, "evens") {
+			return 0.5
+		}
+		parts := strings.Split(priceString, "/")
+		if len(parts) != 2 {
+			log.Fatalf("Invalid fractional odds of venue %s: %s", v.Name, priceString)
+		}
+		numerator := commons.MustParseFloat(parts[0])
+		denominator := commons.MustParseFloat(parts[1])
+		if numerator < 0.0 || denominator <= 0.0 {
+			log.Fatalf("Invalid fractional odds of venue %s: %s", v.Name, priceString)
+		}
+		return denominator / (numerator + denominator)
+	default:
+		return commons.MustParseFloat(priceString)
+	}
+}
\ No newline at end of file